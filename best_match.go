@@ -0,0 +1,108 @@
+package jsonschema
+
+// BestMatch returns the error of errs that is the most relevant to show to a
+// user, like best_match of Python's jsonschema, or nil if errs is empty.
+//
+// An error of "anyOf" or "oneOf" is replaced by the best match among the errors
+// of its most relevant branch. The branches where the instance has the right
+// type come first, then the ones where no "const" or "enum" failed for the
+// instance or its properties, like a discriminator property, then the ones
+// with the fewest errors, then the ones with the deepest errors. Of the
+// resulting errors, the deepest one in the instance is picked.
+func BestMatch(errs []ValidationError) *ValidationError {
+	var best *ValidationError
+	for i := range errs {
+		e := bestLeaf(errs[i])
+		if best == nil || moreRelevant(e, *best) {
+			best = &e
+		}
+	}
+	return best
+}
+
+// bestLeaf follows the branch errors of e to the best match among them. The
+// error of a "oneOf" that more than one schema matched is kept, since not all
+// of its branches failed.
+func bestLeaf(e ValidationError) ValidationError {
+	if len(e.BranchErrors) == 0 {
+		return e
+	}
+	var best []ValidationError
+	for _, branch := range e.BranchErrors {
+		if len(branch) == 0 {
+			return e
+		}
+		if best == nil || betterBranch(branch, best, len(e.Keypath)) {
+			best = branch
+		}
+	}
+	return *BestMatch(best)
+}
+
+// moreRelevant reports whether a is more relevant than b. Errors deeper in the
+// instance are more relevant, and the ones of "anyOf" and "oneOf" are less
+// relevant than the others at the same depth. The other errors are ordered by
+// location, so that the best match doesn't depend on the order of errs.
+func moreRelevant(a, b ValidationError) bool {
+	if len(a.Keypath) != len(b.Keypath) {
+		return len(a.Keypath) > len(b.Keypath)
+	}
+	if weakMatch(a) != weakMatch(b) {
+		return !weakMatch(a)
+	}
+	if a.SchemaPath != b.SchemaPath {
+		return a.SchemaPath < b.SchemaPath
+	}
+	return a.JSONPointer() < b.JSONPointer()
+}
+
+func weakMatch(e ValidationError) bool {
+	return e.Keyword == "anyOf" || e.Keyword == "oneOf"
+}
+
+// betterBranch reports whether the errors of branch a are more relevant than
+// the ones of branch b. The branches were evaluated at an instance location of
+// the given depth.
+func betterBranch(a, b []ValidationError, depth int) bool {
+	if ta, tb := typeMatched(a, depth), typeMatched(b, depth); ta != tb {
+		return ta
+	}
+	if da, db := discriminatorMatched(a, depth), discriminatorMatched(b, depth); da != db {
+		return da
+	}
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return maxDepth(a) > maxDepth(b)
+}
+
+// typeMatched reports whether the instance has a type that a branch allows.
+func typeMatched(branch []ValidationError, depth int) bool {
+	for _, e := range branch {
+		if e.Code == CodeType && len(e.Keypath) == depth {
+			return false
+		}
+	}
+	return true
+}
+
+// discriminatorMatched reports whether the "const" and "enum" keywords of a
+// branch for the instance and its properties passed.
+func discriminatorMatched(branch []ValidationError, depth int) bool {
+	for _, e := range branch {
+		if (e.Code == CodeConst || e.Code == CodeEnum) && len(e.Keypath) <= depth+1 {
+			return false
+		}
+	}
+	return true
+}
+
+func maxDepth(errs []ValidationError) int {
+	var depth int
+	for _, e := range errs {
+		if len(e.Keypath) > depth {
+			depth = len(e.Keypath)
+		}
+	}
+	return depth
+}
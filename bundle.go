@@ -0,0 +1,280 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bundle parses a schema document and loads the external documents it refers
+// to, and returns a single JSON document that doesn't refer to any. baseURI is
+// the URI the document was retrieved from, which can be empty.
+//
+// The external documents are placed under "definitions" ("$defs" since draft
+// 2019-09) of the root schema, keyed by their URI, with an id identifying them
+// by it. The references of the root document to them are rewritten to JSON
+// pointers into the bundle, while the references within them are kept, since
+// they are resolved against their id. Up to draft 7 an id beside "$ref" is
+// ignored, so the references within a document whose root has "$ref" are
+// rewritten like the ones of the root document. References to the
+// meta-schemas of the drafts are kept.
+//
+// External documents are loaded with the loader given with WithLoader, if any.
+func Bundle(r io.Reader, baseURI string, opts ...ParseOption) ([]byte, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	loader := newParseConfig(opts).loader
+	docs := make(map[string][]byte)
+	record := LoaderFunc(func(uri string) (io.Reader, error) {
+		r, err := loader.Load(uri)
+		if err != nil {
+			return nil, err
+		}
+		if closer, ok := r.(io.Closer); ok {
+			defer closer.Close()
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		docs[uri] = b
+		return bytes.NewReader(b), nil
+	})
+	s := &Schema{}
+	opts = append(append([]ParseOption{}, opts...), WithLoader(record), WithBaseURI(baseURI))
+	if err := s.Parse(bytes.NewReader(b), true, opts...); err != nil {
+		return nil, err
+	}
+
+	root, err := decodeTree(b)
+	if err != nil {
+		return nil, err
+	}
+	rootObject, ok := root.(map[string]interface{})
+	if !ok {
+		if len(docs) == 0 {
+			return b, nil
+		}
+		return nil, errors.New("the root schema must be an object to bundle documents into it")
+	}
+	defsKey := "definitions"
+	if s.draft >= Draft2019 {
+		defsKey = "$defs"
+	}
+
+	// Find the document of each schema. The root document has an empty URI.
+	schemaDocs := make(map[*Schema]string)
+	walkResources(s, func(sch, resource *Schema) {
+		schemaDocs[sch] = ""
+	})
+	docSchemas := make(map[string]*Schema, len(docs))
+	for uri := range docs {
+		key, err := resolveCacheKey(uri)
+		if err != nil {
+			return nil, err
+		}
+		doc, ok := s.Cache[key]
+		if !ok {
+			return nil, fmt.Errorf("document %s wasn't parsed", uri)
+		}
+		docSchemas[uri] = doc
+		walkResources(doc, func(sch, resource *Schema) {
+			if _, ok := schemaDocs[sch]; !ok {
+				schemaDocs[sch] = uri
+			}
+		})
+	}
+
+	uris := make([]string, 0, len(docs))
+	trees := make(map[string]interface{}, len(docs)+1)
+	trees[""] = root
+	for uri := range docs {
+		uris = append(uris, uri)
+		if trees[uri], err = decodeTree(docs[uri]); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(uris)
+	// Up to draft 7 the id of a document whose root has "$ref" is ignored, so
+	// once bundled its references are resolved against the root document.
+	baseless := func(uri string) bool {
+		if uri == "" {
+			return true
+		}
+		_, hasRef := docSchemas[uri].hasRef()
+		return hasRef && docSchemas[uri].draft < Draft2019
+	}
+
+	// Rewrite the references of the root document, and of the documents that
+	// are resolved against it, to JSON pointers into the bundle, unless they
+	// are in a subschema with an id of its own. The references to these
+	// documents from the others are rewritten to the id of the root document.
+	var rewriteErr error
+	needsRootID := false
+	for _, uri := range append([]string{""}, uris...) {
+		doc := s
+		if uri != "" {
+			doc = docSchemas[uri]
+		}
+		walkResources(doc, func(sch, resource *Schema) {
+			r, ok := sch.hasRef()
+			if !ok || r.target == nil || resource != doc {
+				return
+			}
+			target, ok := schemaDocs[r.target]
+			if !ok {
+				return
+			}
+			var ref string
+			switch {
+			case target == "":
+				// The external documents refer to the root document by its id.
+				needsRootID = needsRootID || !baseless(uri)
+				if uri == "" || !baseless(uri) {
+					return
+				}
+				ref = (&url.URL{Fragment: r.target.pointer}).String()
+			case baseless(uri):
+				ref = (&url.URL{Fragment: "/" + defsKey + "/" + escapePointerToken(target) + r.target.pointer}).String()
+			case baseless(target):
+				if s.id == "" {
+					rewriteErr = fmt.Errorf("%s refers to %s, which can't be bundled without an id for the root document", uri, target)
+					return
+				}
+				needsRootID = true
+				ref = s.id + (&url.URL{Fragment: "/" + defsKey + "/" + escapePointerToken(target) + r.target.pointer}).String()
+			default:
+				return
+			}
+			if err := setPointer(trees[uri], sch.pointer+"/$ref", ref); err != nil && rewriteErr == nil {
+				rewriteErr = err
+			}
+		})
+	}
+	if rewriteErr != nil {
+		return nil, rewriteErr
+	}
+	if needsRootID && s.id != "" {
+		if _, ok := rootObject[s.draft.idKeyword()]; !ok {
+			rootObject[s.draft.idKeyword()] = s.id
+		}
+	}
+
+	if len(docs) > 0 {
+		defs, ok := rootObject[defsKey].(map[string]interface{})
+		if !ok {
+			if _, exists := rootObject[defsKey]; exists {
+				return nil, fmt.Errorf("%s of the root schema isn't an object", defsKey)
+			}
+			defs = make(map[string]interface{}, len(docs))
+			rootObject[defsKey] = defs
+		}
+		for _, uri := range uris {
+			if _, exists := defs[uri]; exists {
+				return nil, fmt.Errorf("%s of the root schema already has %s", defsKey, uri)
+			}
+			if object, ok := trees[uri].(map[string]interface{}); ok && !baseless(uri) {
+				object[docSchemas[uri].draft.idKeyword()] = docSchemas[uri].id
+			}
+			defs[uri] = trees[uri]
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// BundleFile bundles the schema in a file, like ParseFile parses it.
+func BundleFile(path string, opts ...ParseOption) ([]byte, error) {
+	uri, err := fileURI(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]ParseOption{WithLoader(fileLoader())}, opts...)
+	return Bundle(bytes.NewReader(b), uri, opts...)
+}
+
+// walkResources calls fn for s and each of its subschemas, along with the schema
+// resource that contains them.
+func walkResources(s *Schema, fn func(sch, resource *Schema)) {
+	var walk func(sch, resource *Schema)
+	visited := make(map[*Schema]bool)
+	walk = func(sch, resource *Schema) {
+		if visited[sch] {
+			return
+		}
+		visited[sch] = true
+		if sch.isResource {
+			resource = sch
+		}
+		fn(sch, resource)
+		for _, n := range sch.nodes {
+			for _, embedded := range n.EmbeddedSchemas {
+				walk(embedded, resource)
+			}
+		}
+	}
+	walk(s, s)
+}
+
+func decodeTree(b []byte) (interface{}, error) {
+	var tree interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// setPointer sets the value that a JSON pointer refers to in a decoded JSON
+// document. The parent of the value must exist.
+func setPointer(tree interface{}, pointer string, value interface{}) error {
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	for i, token := range tokens {
+		last := i == len(tokens)-1
+		switch t := tree.(type) {
+		case map[string]interface{}:
+			if last {
+				t[token] = value
+				return nil
+			}
+			tree = t[token]
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(t) {
+				return fmt.Errorf("%s doesn't exist", pointer)
+			}
+			if last {
+				t[index] = value
+				return nil
+			}
+			tree = t[index]
+		default:
+			return fmt.Errorf("%s doesn't exist", pointer)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+// Command jsonschema-bundle writes a schema and the external schemas it refers
+// to as a single self-contained document.
+//
+// Usage:
+//
+//	jsonschema-bundle [-o output.json] [-timeout 10s] [-max-size bytes] [-allow-host host,...] schema.json|URL
+//
+// The schema is read from a file, or fetched if it is an http:// or https://
+// URL. References to other files are resolved against the directory of the
+// schema.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cupcake/jsonschema"
+)
+
+func main() {
+	output := flag.String("o", "", "write the bundle to this file instead of the standard output")
+	timeout := flag.Duration("timeout", 30*time.Second, "the maximum time to fetch a remote schema")
+	maxSize := flag.Int64("max-size", 0, "the maximum size of a remote schema in bytes, if positive")
+	allowHosts := flag.String("allow-host", "", "a comma-separated list of the hosts that remote schemas can be fetched from")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: jsonschema-bundle [flags] schema.json|URL")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	httpLoader := jsonschema.HTTPLoader{Timeout: *timeout, MaxBodySize: *maxSize}
+	if *allowHosts != "" {
+		httpLoader.AllowedHosts = strings.Split(*allowHosts, ",")
+	}
+	loader := jsonschema.SchemeLoader{"file": jsonschema.FileLoader{}, "http": httpLoader, "https": httpLoader}
+
+	source := flag.Arg(0)
+	var bundle []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		r, loadErr := httpLoader.Load(source)
+		if loadErr != nil {
+			fatal(loadErr)
+		}
+		bundle, err = jsonschema.Bundle(r, source, jsonschema.WithLoader(loader))
+	} else {
+		bundle, err = jsonschema.BundleFile(source, jsonschema.WithLoader(loader))
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if *output == "" {
+		os.Stdout.Write(bundle)
+		return
+	}
+	if err := ioutil.WriteFile(*output, bundle, 0644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "jsonschema-bundle:", err)
+	os.Exit(1)
+}
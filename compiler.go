@@ -0,0 +1,133 @@
+package jsonschema
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sync"
+)
+
+// A Compiler compiles schemas from a set of resources that can refer to each
+// other, such as the schemas of many services that are loaded once at startup.
+// Each document is parsed once, and the schemas it declares are shared by all
+// the schemas compiled afterwards. It is safe for concurrent use.
+type Compiler struct {
+	opts []ParseOption
+	// The loader of documents that weren't added as resources, or nil if only
+	// resources can be compiled.
+	fallback Loader
+
+	mu        sync.RWMutex
+	resources map[string][]byte
+	cache     map[string]*Schema
+}
+
+// NewCompiler returns a compiler whose documents are parsed with opts. Documents
+// that aren't added with AddResource are loaded with the loader given with
+// WithLoader, if any.
+func NewCompiler(opts ...ParseOption) *Compiler {
+	c := &parseConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return &Compiler{
+		opts:      opts,
+		fallback:  c.loader,
+		resources: make(map[string][]byte),
+		cache:     make(map[string]*Schema),
+	}
+}
+
+// AddResource adds the document retrieved from an absolute URI, which is
+// compiled when it or a schema referring to it is compiled.
+func (c *Compiler) AddResource(uri string, r io.Reader) error {
+	key, err := resolveCacheKey(uri)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.resources[key]; ok {
+		return fmt.Errorf("resource %s is already added", uri)
+	}
+	c.resources[key] = b
+	return nil
+}
+
+// Compile returns the schema identified by an absolute URI, like
+// "https://example.com/order.json" or "https://example.com/order.json#/definitions/line".
+// The document containing it is compiled if it wasn't already.
+func (c *Compiler) Compile(uri string) (*Schema, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	key, err := resolveCacheKey(uri)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	doc, ok := c.cache[key]
+	c.mu.RUnlock()
+	if !ok {
+		docURL := *u
+		docURL.Fragment, docURL.RawFragment = "", ""
+		c.mu.Lock()
+		doc, err = c.compileDocument(docURL.String(), key)
+		c.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if u.Fragment == "" {
+		return doc, nil
+	}
+	return doc.refToSchema("#"+u.EscapedFragment(), doc, &resolver{cache: doc.Cache})
+}
+
+// compileDocument parses a document and the documents it refers to. The cache
+// is only updated if they are all valid. c.mu must be locked.
+func (c *Compiler) compileDocument(uri, key string) (*Schema, error) {
+	if doc, ok := c.cache[key]; ok {
+		return doc, nil
+	}
+	r, err := c.load(uri)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := r.(io.Closer); ok {
+		defer closer.Close()
+	}
+	cache := make(map[string]*Schema, len(c.cache))
+	for k, v := range c.cache {
+		cache[k] = v
+	}
+	opts := append(append([]ParseOption{}, c.opts...), WithLoader(LoaderFunc(c.load)), WithBaseURI(uri))
+	doc, err := ParseWithCache(r, true, &cache, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("compiling %s: %s", uri, err)
+	}
+	cache[key] = doc
+	c.cache = cache
+	return doc, nil
+}
+
+// load loads a document from the resources, or with the fallback loader. c.mu
+// must be locked.
+func (c *Compiler) load(uri string) (io.Reader, error) {
+	if key, err := resolveCacheKey(uri); err == nil {
+		if b, ok := c.resources[key]; ok {
+			return bytes.NewReader(b), nil
+		}
+	}
+	if c.fallback == nil {
+		return nil, fmt.Errorf("%s is not an added resource", uri)
+	}
+	return c.fallback.Load(uri)
+}
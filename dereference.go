@@ -0,0 +1,169 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Dereference returns the schema as a JSON document in which each "$ref" is
+// replaced by the schema it refers to, for tools that can't follow references.
+// Up to draft 7 the schema containing "$ref" is replaced, since its other
+// keywords are ignored. Since draft 2019-09 the schema referred to is added to
+// the "allOf" of the schema containing "$ref", unless "$ref" is its only keyword.
+//
+// A recursive reference, to a schema that contains it or that is being replaced
+// by it, is kept and refers to the location of that schema in the returned
+// document, relative to the innermost schema with an id containing the
+// reference. References that aren't resolved, "$recursiveRef" and "$dynamicRef"
+// are kept as they are.
+func (s *Schema) Dereference() ([]byte, error) {
+	d := &dereferencer{active: make(map[*Schema]string), resources: []outputResource{{}}}
+	v, err := d.schema(s, "")
+	if err != nil {
+		return nil, err
+	}
+	return encodeJSON(v)
+}
+
+// MarshalJSON returns the schema as a JSON document equivalent to the one it was
+// parsed from. Its embedded schemas are marshaled from the schemas they were
+// parsed into, and its other keywords, including unknown ones, are kept as
+// they were.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	if s.boolean == nil && s.raw == nil {
+		return []byte("{}"), nil
+	}
+	v, err := (&dereferencer{keepRefs: true, active: make(map[*Schema]string)}).schema(s, "")
+	if err != nil {
+		return nil, err
+	}
+	return encodeJSON(v)
+}
+
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// A dereferencer builds the JSON document of a schema from its keywords and
+// embedded schemas.
+type dereferencer struct {
+	// Set if references are kept as they are instead of being replaced.
+	keepRefs bool
+	// The schemas being dereferenced, which references to are recursive, with
+	// their JSON pointers into the document being built.
+	active map[*Schema]string
+	// The schemas with an id that contain the schema being dereferenced,
+	// innermost last, after the root of the document.
+	resources []outputResource
+}
+
+// An outputResource is a schema with an id in the document being built.
+type outputResource struct {
+	pointer, id string
+}
+
+// schema returns the JSON value of s, which is placed in the document being built
+// at pointer.
+func (d *dereferencer) schema(s *Schema, pointer string) (interface{}, error) {
+	if s.boolean != nil {
+		return *s.boolean, nil
+	}
+	_, active := d.active[s]
+	if !active {
+		d.active[s] = pointer
+		defer delete(d.active, s)
+	}
+	r, hasRef := s.hasRef()
+	hasRef = hasRef && !d.keepRefs && r.target != nil
+	if hasRef && (s.draft < Draft2019 || len(s.raw) == 1) {
+		if _, recursive := d.active[r.target]; !recursive {
+			return d.schema(r.target, pointer)
+		}
+	}
+	if _, ok := s.raw[s.draft.idKeyword()]; ok && s.isResource && !d.keepRefs {
+		d.resources = append(d.resources, outputResource{pointer, s.id})
+		defer func() { d.resources = d.resources[:len(d.resources)-1] }()
+	}
+	out := make(map[string]interface{}, len(s.raw))
+	for key, value := range s.raw {
+		n, ok := s.nodes[key]
+		if _, unknown := n.Validator.(*other); !ok || unknown || len(n.EmbeddedSchemas) == 0 {
+			out[key] = value
+			continue
+		}
+		// The embedded schemas are put back in the value of the keyword where
+		// their pointers say they were.
+		tree, err := decodeTree(value)
+		if err != nil {
+			return nil, err
+		}
+		keywordPointer := s.pointer + "/" + escapePointerToken(key)
+		for _, sch := range n.EmbeddedSchemas {
+			rel := strings.TrimPrefix(sch.pointer, keywordPointer)
+			v, err := d.schema(sch, pointer+"/"+escapePointerToken(key)+rel)
+			if err != nil {
+				return nil, err
+			}
+			if rel != "" {
+				err = setPointer(tree, rel, v)
+			} else {
+				tree = v
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		out[key] = tree
+	}
+	if hasRef {
+		if targetPointer, recursive := d.active[r.target]; recursive {
+			location, err := d.location(targetPointer)
+			if err != nil {
+				return nil, err
+			}
+			out["$ref"] = location
+		} else {
+			delete(out, "$ref")
+			allOf, _ := out["allOf"].([]interface{})
+			target, err := d.schema(r.target, pointer+"/allOf/"+strconv.Itoa(len(allOf)))
+			if err != nil {
+				return nil, err
+			}
+			out["allOf"] = append(allOf, target)
+		}
+	}
+	return out, nil
+}
+
+// location returns a reference to the schema at a JSON pointer into the
+// document being built, from the schema being dereferenced. It is a fragment if
+// both are in the same schema with an id, and otherwise an absolute URI.
+func (d *dereferencer) location(pointer string) (string, error) {
+	for i := len(d.resources) - 1; i >= 0; i-- {
+		resource := d.resources[i]
+		if pointer != resource.pointer && !strings.HasPrefix(pointer, resource.pointer+"/") {
+			continue
+		}
+		fragment := "#" + (&url.URL{Fragment: strings.TrimPrefix(pointer, resource.pointer)}).EscapedFragment()
+		if i == len(d.resources)-1 {
+			return fragment, nil
+		}
+		u, err := url.Parse(resource.id)
+		if err != nil || !u.IsAbs() {
+			return "", fmt.Errorf("the schema at %s has no absolute id to refer to it by", resource.pointer)
+		}
+		u.Fragment, u.RawFragment = "", ""
+		return u.String() + fragment, nil
+	}
+	return "", fmt.Errorf("%s isn't in the document", pointer)
+}
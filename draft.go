@@ -0,0 +1,215 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// A Draft is a version of the JSON Schema specification. The draft of a schema
+// decides which keywords it understands and how some of them behave.
+type Draft int
+
+const (
+	Draft3 Draft = 3
+	Draft4 Draft = 4
+	Draft6 Draft = 6
+	Draft7 Draft = 7
+	// Draft 2019-09
+	Draft2019 Draft = 2019
+	// Draft 2020-12
+	Draft2020 Draft = 2020
+)
+
+// The draft used for schemas that don't declare one with "$schema".
+const defaultDraft = Draft4
+
+var draftURIs = map[string]Draft{
+	"http://json-schema.org/draft-03/schema":       Draft3,
+	"http://json-schema.org/draft-04/schema":       Draft4,
+	"http://json-schema.org/draft-06/schema":       Draft6,
+	"http://json-schema.org/draft-07/schema":       Draft7,
+	"https://json-schema.org/draft/2019-09/schema": Draft2019,
+	"https://json-schema.org/draft/2020-12/schema": Draft2020,
+}
+
+var draft4Validators = map[string]reflect.Type{
+	// Numbers
+	"maximum":    reflect.TypeOf(maximum{}),
+	"minimum":    reflect.TypeOf(minimum{}),
+	"multipleOf": reflect.TypeOf(multipleOf{}),
+
+	// Strings
+	"maxLength": reflect.TypeOf(maxLength(0)),
+	"minLength": reflect.TypeOf(minLength(0)),
+	"pattern":   reflect.TypeOf(pattern{}),
+	"format":    reflect.TypeOf(format("")),
+
+	// Arrays
+	"additionalItems": reflect.TypeOf(additionalItems{}),
+	"maxItems":        reflect.TypeOf(maxItems(0)),
+	"minItems":        reflect.TypeOf(minItems(0)),
+	"items":           reflect.TypeOf(items{}),
+	"uniqueItems":     reflect.TypeOf(uniqueItems(false)),
+
+	// Objects
+	"additionalProperties": reflect.TypeOf(additionalProperties{}),
+	"dependencies":         reflect.TypeOf(dependencies{}),
+	"maxProperties":        reflect.TypeOf(maxProperties(0)),
+	"minProperties":        reflect.TypeOf(minProperties(0)),
+	"patternProperties":    reflect.TypeOf(patternProperties{}),
+	"properties":           reflect.TypeOf(properties{}),
+	"required":             reflect.TypeOf(required{}),
+
+	// All types
+	"allOf":       reflect.TypeOf(allOf{}),
+	"anyOf":       reflect.TypeOf(anyOf{}),
+	"definitions": reflect.TypeOf(definitions{}),
+	"enum":        reflect.TypeOf(enum{}),
+	"not":         reflect.TypeOf(not{}),
+	"oneOf":       reflect.TypeOf(oneOf{}),
+	"$ref":        reflect.TypeOf(ref{}),
+	"type":        reflect.TypeOf(typeValidator{})}
+
+// Draft 4 replaced divisibleBy, disallow and extends with multipleOf, not and
+// allOf, and moved required from the property schemas to their parent.
+var draft3Validators = extendValidators(withoutValidators(draft4Validators,
+	"multipleOf", "maxProperties", "minProperties", "required", "allOf", "anyOf", "not", "oneOf"), map[string]reflect.Type{
+	// Numbers
+	"divisibleBy": reflect.TypeOf(divisibleBy{}),
+
+	// Objects
+	"required": reflect.TypeOf(draft3Required(false)),
+
+	// All
+	"disallow": reflect.TypeOf(disallow{}),
+	"extends":  reflect.TypeOf(extends{}),
+	"type":     reflect.TypeOf(draft3Type{}),
+})
+
+var draft6Validators = extendValidators(draft4Validators, map[string]reflect.Type{
+	// Numbers
+	"exclusiveMaximum": reflect.TypeOf(exclusiveMaximum{}),
+	"exclusiveMinimum": reflect.TypeOf(exclusiveMinimum{}),
+
+	// Arrays
+	"contains": reflect.TypeOf(contains{}),
+
+	// Objects
+	"propertyNames": reflect.TypeOf(propertyNames{}),
+
+	// All types
+	"const": reflect.TypeOf(constValidator{}),
+	// "examples": covered by the hardcoded `other` validator.
+})
+
+var draft7Validators = extendValidators(draft6Validators, map[string]reflect.Type{
+	// Conditionals
+	"if":   reflect.TypeOf(ifValidator{}),
+	"then": reflect.TypeOf(thenValidator{}),
+	"else": reflect.TypeOf(elseValidator{}),
+
+	// "$comment", "readOnly", "writeOnly", "contentEncoding" and "contentMediaType":
+	// annotations covered by the hardcoded `other` validator.
+})
+
+// In draft 2019-09 dependencies was split into dependentRequired and dependentSchemas.
+var draft2019Validators = extendValidators(withoutValidators(draft7Validators, "dependencies"), map[string]reflect.Type{
+	// Arrays
+	"maxContains":      reflect.TypeOf(maxContains(0)),
+	"minContains":      reflect.TypeOf(minContains(0)),
+	"unevaluatedItems": reflect.TypeOf(unevaluatedItems{}),
+
+	// Objects
+	"dependentRequired":     reflect.TypeOf(dependentRequired{}),
+	"dependentSchemas":      reflect.TypeOf(dependentSchemas{}),
+	"unevaluatedProperties": reflect.TypeOf(unevaluatedProperties{}),
+
+	// All
+	"$defs":         reflect.TypeOf(definitions{}),
+	"$recursiveRef": reflect.TypeOf(recursiveRef{}),
+
+	// "deprecated": an annotation covered by the hardcoded `other` validator.
+})
+
+// In draft 2020-12 the array form of items was replaced by prefixItems, and
+// additionalItems by the schema form of items. $recursiveRef was replaced by
+// $dynamicRef.
+var draft2020Validators = extendValidators(withoutValidators(draft2019Validators, "additionalItems", "$recursiveRef"), map[string]reflect.Type{
+	// Arrays
+	"prefixItems": reflect.TypeOf(prefixItems{}),
+
+	// All
+	"$dynamicRef": reflect.TypeOf(dynamicRef{}),
+})
+
+// extendValidators returns a copy of base with additions added to it.
+func extendValidators(base, additions map[string]reflect.Type) map[string]reflect.Type {
+	m := make(map[string]reflect.Type, len(base)+len(additions))
+	for k, v := range base {
+		m[k] = v
+	}
+	for k, v := range additions {
+		m[k] = v
+	}
+	return m
+}
+
+// withoutValidators returns a copy of base without the validators of keys.
+func withoutValidators(base map[string]reflect.Type, keys ...string) map[string]reflect.Type {
+	m := extendValidators(base, nil)
+	for _, k := range keys {
+		delete(m, k)
+	}
+	return m
+}
+
+// draftFromSchemaKeyword returns the draft identified by the value of a
+// "$schema" keyword, if it is one we know.
+func draftFromSchemaKeyword(b json.RawMessage) (Draft, bool) {
+	var uri string
+	if err := json.Unmarshal(b, &uri); err != nil {
+		return 0, false
+	}
+	d, ok := draftURIs[strings.TrimSuffix(uri, "#")]
+	return d, ok
+}
+
+func (d Draft) validators() map[string]reflect.Type {
+	switch {
+	case d >= Draft2020:
+		return draft2020Validators
+	case d >= Draft2019:
+		return draft2019Validators
+	case d >= Draft7:
+		return draft7Validators
+	case d >= Draft6:
+		return draft6Validators
+	case d >= Draft4:
+		return draft4Validators
+	}
+	return draft3Validators
+}
+
+// idKeyword returns the keyword used to change the resolution scope of a schema.
+func (d Draft) idKeyword() string {
+	if d >= Draft6 {
+		return "$id"
+	}
+	return "id"
+}
+
+// A DraftSetter is a validator (such as type) whose validate method depends on
+// the draft of the schema it belongs to. When a DraftSetter is unmarshaled from
+// JSON, SetDraft is called with the draft of its schema.
+type DraftSetter interface {
+	SetDraft(Draft)
+}
+
+// An entryChecker is a validator (such as dependencies) whose keyword is an
+// object of independent entries. The invalid entries are left out, so that
+// they don't invalidate the whole keyword, and invalidEntries returns their
+// errors by name once SetDraft has been called.
+type entryChecker interface {
+	invalidEntries() map[string]error
+}
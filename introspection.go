@@ -0,0 +1,165 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// Keywords returns the keywords of the schema in alphabetical order, including
+// the ones that aren't known in its draft. A boolean schema has none.
+func (s *Schema) Keywords() []string {
+	keywords := make([]string, 0, len(s.raw))
+	for key := range s.raw {
+		keywords = append(keywords, key)
+	}
+	sort.Strings(keywords)
+	return keywords
+}
+
+// Properties returns the schemas of the "properties" keyword by property name,
+// or nil if the schema doesn't have it.
+func (s *Schema) Properties() map[string]*Schema {
+	n, ok := s.nodes["properties"]
+	if !ok {
+		return nil
+	}
+	properties := make(map[string]*Schema, len(n.EmbeddedSchemas))
+	for name, sch := range n.EmbeddedSchemas {
+		properties[name] = sch
+	}
+	return properties
+}
+
+// Required returns the names of the required properties in alphabetical order.
+// In draft 3, these are the properties whose schemas have a true "required",
+// following their references.
+func (s *Schema) Required() []string {
+	var names []string
+	if s.draft == Draft3 {
+		for name, sch := range s.Properties() {
+			if requiredProperty(sch) {
+				names = append(names, name)
+			}
+		}
+	} else {
+		s.decodeKeyword("required", &names)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Items returns the schema of the "items" keyword. If "items" is an array of
+// schemas, schema is nil and tuple has the schemas by position.
+func (s *Schema) Items() (schema *Schema, tuple []*Schema) {
+	n, ok := s.nodes["items"]
+	if !ok {
+		return nil, nil
+	}
+	if sch, ok := n.EmbeddedSchemas[""]; ok {
+		return sch, nil
+	}
+	tuple = make([]*Schema, len(n.EmbeddedSchemas))
+	for key, sch := range n.EmbeddedSchemas {
+		if i, err := strconv.Atoi(key); err == nil && i < len(tuple) {
+			tuple[i] = sch
+		}
+	}
+	return nil, tuple
+}
+
+// Enum returns the values of the "enum" keyword, or nil if the schema doesn't
+// have it. Numbers are json.Numbers.
+func (s *Schema) Enum() []interface{} {
+	var values []interface{}
+	s.decodeKeyword("enum", &values)
+	return values
+}
+
+// Type returns the types of the "type" keyword, which can be a single type or
+// an array of types. In draft 3, the schemas among the types are left out.
+func (s *Schema) Type() []string {
+	var typ string
+	if s.decodeKeyword("type", &typ) {
+		return []string{typ}
+	}
+	var values []interface{}
+	s.decodeKeyword("type", &values)
+	var types []string
+	for _, v := range values {
+		if t, ok := v.(string); ok {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// Format returns the value of the "format" keyword.
+func (s *Schema) Format() string {
+	var format string
+	s.decodeKeyword("format", &format)
+	return format
+}
+
+// Title returns the value of the "title" keyword.
+func (s *Schema) Title() string {
+	var title string
+	s.decodeKeyword("title", &title)
+	return title
+}
+
+// Description returns the value of the "description" keyword.
+func (s *Schema) Description() string {
+	var description string
+	s.decodeKeyword("description", &description)
+	return description
+}
+
+// Default returns the value of the "default" keyword, and whether the schema
+// has it. Numbers are json.Numbers.
+func (s *Schema) Default() (interface{}, bool) {
+	var value interface{}
+	ok := s.decodeKeyword("default", &value)
+	return value, ok
+}
+
+// decodeKeyword decodes the value of a keyword into v, and reports whether it
+// succeeded.
+func (s *Schema) decodeKeyword(key string, v interface{}) bool {
+	b, ok := s.raw[key]
+	if !ok {
+		return false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	return decoder.Decode(v) == nil
+}
+
+// Walk calls fn for the schema and each of its embedded schemas, depth-first,
+// with the JSON pointer to the schema in its document. References aren't
+// followed, and neither are unknown keywords, which aren't known to hold
+// schemas. If fn returns an error, the walk stops and Walk returns it.
+func (s *Schema) Walk(fn func(pointer string, s *Schema) error) error {
+	if err := fn(s.pointer, s); err != nil {
+		return err
+	}
+	var embedded []*Schema
+	for _, n := range s.nodes {
+		if _, unknown := n.Validator.(*other); unknown {
+			continue
+		}
+		for _, sch := range n.EmbeddedSchemas {
+			embedded = append(embedded, sch)
+		}
+	}
+	sort.Slice(embedded, func(i, j int) bool {
+		return embedded[i].pointer < embedded[j].pointer
+	})
+	for _, sch := range embedded {
+		if err := sch.Walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,128 +3,534 @@ package jsonschema
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
 
 	jsonpointer "github.com/rnd42/go-jsonpointer"
 )
 
-var validatorMap = map[string]reflect.Type{
-	// Numbers
-	"maximum":    reflect.TypeOf(maximum{}),
-	"minimum":    reflect.TypeOf(minimum{}),
-	"multipleOf": reflect.TypeOf(multipleOf(0)),
-
-	// Strings
-	"maxLength": reflect.TypeOf(maxLength(0)),
-	"minLength": reflect.TypeOf(minLength(0)),
-	"pattern":   reflect.TypeOf(pattern{}),
-	"format":    reflect.TypeOf(format("")),
-
-	// Arrays
-	"additionalItems": reflect.TypeOf(additionalItems{}),
-	"maxItems":        reflect.TypeOf(maxItems(0)),
-	"minItems":        reflect.TypeOf(minItems(0)),
-	"items":           reflect.TypeOf(items{}),
-
-	// Objects
-	"additionalProperties": reflect.TypeOf(additionalProperties{}),
-	"dependencies":         reflect.TypeOf(dependencies{}),
-	"maxProperties":        reflect.TypeOf(maxProperties(0)),
-	"minProperties":        reflect.TypeOf(minProperties(0)),
-	"patternProperties":    reflect.TypeOf(patternProperties{}),
-	"properties":           reflect.TypeOf(properties{}),
-	"required":             reflect.TypeOf(required{}),
-
-	// All types
-	"allOf": reflect.TypeOf(allOf{}),
-	"anyOf": reflect.TypeOf(anyOf{}),
-	// "definitions": covered by the hardcoded `other` validator.
-	"enum":  reflect.TypeOf(enum{}),
-	"not":   reflect.TypeOf(not{}),
-	"oneOf": reflect.TypeOf(oneOf{}),
-	"$ref":  reflect.TypeOf(ref("")),
-	"type":  reflect.TypeOf(typeValidator{})}
-
 type Validator interface {
 	Validate([]string, interface{}) []ValidationError
 }
 
-func Parse(schemaBytes io.Reader, loadExternalSchemas bool) (*Schema, error) {
+// An Evaluator is a validator (such as properties or allOf) that applies subschemas
+// to the data or to parts of it. Besides the validation errors, its Evaluate method
+// reports which properties and items of the data it evaluated, which the
+// unevaluatedProperties and unevaluatedItems keywords depend on.
+type Evaluator interface {
+	Evaluate(Scope, []string, interface{}) Result
+}
+
+// An UnevaluatedChecker is a validator (such as unevaluatedProperties) that only
+// applies to the parts of the data that its neighbors didn't evaluate. It is run
+// after all of its neighbors, with their combined Result.
+type UnevaluatedChecker interface {
+	EvaluateUnevaluated(Scope, []string, interface{}, Result) Result
+}
+
+// A Scope is the dynamic scope of an evaluation: the schema resources that were
+// entered on the way to the schema being evaluated, from the outermost to the
+// innermost. "$recursiveRef" and "$dynamicRef" are resolved against it.
+type Scope []*Schema
+
+// A Result is the outcome of evaluating data against a schema or a validator.
+type Result struct {
+	Errors []ValidationError
+	// The names of the object properties that were evaluated.
+	EvaluatedProperties map[string]bool
+	// The indexes of the array items that were evaluated.
+	EvaluatedItems map[int]bool
+}
+
+func (r *Result) evaluatedProperty(name string) {
+	if r.EvaluatedProperties == nil {
+		r.EvaluatedProperties = make(map[string]bool)
+	}
+	r.EvaluatedProperties[name] = true
+}
+
+func (r *Result) evaluatedItem(index int) {
+	if r.EvaluatedItems == nil {
+		r.EvaluatedItems = make(map[int]bool)
+	}
+	r.EvaluatedItems[index] = true
+}
+
+// merge adds the errors and annotations of o to r.
+func (r *Result) merge(o Result) {
+	r.Errors = append(r.Errors, o.Errors...)
+	for name := range o.EvaluatedProperties {
+		r.evaluatedProperty(name)
+	}
+	for index := range o.EvaluatedItems {
+		r.evaluatedItem(index)
+	}
+}
+
+func evaluate(validator Validator, scope Scope, keypath []string, v interface{}) Result {
+	if e, ok := validator.(Evaluator); ok {
+		return e.Evaluate(scope, keypath, v)
+	}
+	return Result{Errors: validator.Validate(keypath, v)}
+}
+
+// A ParseOption changes how a schema is parsed.
+type ParseOption func(*parseConfig)
+
+type parseConfig struct {
+	// The draft of schemas that don't declare one with "$schema".
+	draft Draft
+	// Set if a "$schema" that isn't a known draft is an error.
+	disallowUnknownDialects bool
+	// Set if schemas are validated against the meta-schema of their draft.
+	validateAgainstMetaSchema bool
+	// Set if keywords whose values can't be decoded are errors.
+	strict bool
+	// Set if references that can't be resolved are ignored.
+	lenientRefs bool
+	// The loader of external documents.
+	loader Loader
+	// The URI of the document, which its relative references are resolved against.
+	baseURI string
+
+	// The errors found while compiling a schema.
+	keywordErrors KeywordErrors
+}
+
+// WithDraft sets the draft used for schemas that don't declare one with
+// "$schema". Without it, those schemas are parsed as draft 4 schemas.
+func WithDraft(d Draft) ParseOption {
+	return func(c *parseConfig) {
+		c.draft = d
+	}
+}
+
+// DisallowUnknownDialects makes parsing fail if a schema declares a "$schema"
+// that isn't a known draft. Without it, such a "$schema" is ignored and the
+// schema is parsed according to the draft inherited from its parent.
+func DisallowUnknownDialects() ParseOption {
+	return func(c *parseConfig) {
+		c.disallowUnknownDialects = true
+	}
+}
+
+// ValidateAgainstMetaSchema makes parsing fail with a *MetaSchemaError if the
+// schema isn't valid against the meta-schema of its draft. The meta-schemas are
+// bundled with the package.
+func ValidateAgainstMetaSchema() ParseOption {
+	return func(c *parseConfig) {
+		c.validateAgainstMetaSchema = true
+	}
+}
+
+// Strict makes parsing fail with KeywordErrors if the values of keywords can't
+// be decoded, like a "pattern" that isn't a valid regular expression or a
+// negative "maxLength". Without it, such keywords are ignored.
+func Strict() ParseOption {
+	return func(c *parseConfig) {
+		c.strict = true
+	}
+}
+
+// LenientRefs makes the parse functions ignore references that can't be resolved,
+// instead of failing with UnresolvedRefs. A reference that isn't resolved
+// accepts any instance.
+func LenientRefs() ParseOption {
+	return func(c *parseConfig) {
+		c.lenientRefs = true
+	}
+}
+
+// WithLoader sets the loader that the documents of external references are
+// loaded with, when loading external schemas is enabled. By default they are
+// fetched over HTTP with the default client.
+func WithLoader(l Loader) ParseOption {
+	return func(c *parseConfig) {
+		c.loader = l
+	}
+}
+
+// WithBaseURI sets the URI that a document was retrieved from. A relative id of
+// the root schema, or relative references if it has no id, are resolved against
+// it, and the document is cached by it.
+func WithBaseURI(uri string) ParseOption {
+	return func(c *parseConfig) {
+		c.baseURI = uri
+	}
+}
+
+func newParseConfig(opts []ParseOption) *parseConfig {
+	c := &parseConfig{draft: defaultDraft, loader: defaultLoader}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func Parse(schemaBytes io.Reader, loadExternalSchemas bool, opts ...ParseOption) (*Schema, error) {
 	s := &Schema{}
-	return s, s.Parse(schemaBytes, loadExternalSchemas)
+	return s, s.Parse(schemaBytes, loadExternalSchemas, opts...)
 }
 
-func ParseWithCache(schemaBytes io.Reader, loadExternalSchemas bool, schemaCache *map[string]*Schema) (*Schema, error) {
+func ParseWithCache(schemaBytes io.Reader, loadExternalSchemas bool, schemaCache *map[string]*Schema, opts ...ParseOption) (*Schema, error) {
 	s := &Schema{}
 	s.Cache = *schemaCache
-	return s, s.Parse(schemaBytes, loadExternalSchemas)
+	return s, s.Parse(schemaBytes, loadExternalSchemas, opts...)
+}
+
+// ParseFile parses the schema in a file. The file:// URI of its canonical path is
+// the base URI of the schema, so that relative references like
+// "common/address.json" are loaded from the directory of the file. References
+// to other documents are loaded with FileLoader, or HTTPLoader for http:// and
+// https:// URIs, unless a loader is given with WithLoader.
+func ParseFile(path string, opts ...ParseOption) (*Schema, error) {
+	uri, err := fileURI(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	opts = append([]ParseOption{WithLoader(fileLoader()), WithBaseURI(uri)}, opts...)
+	return Parse(f, true, opts...)
+}
+
+// fileLoader returns the loader of ParseFile and BundleFile, which loads files
+// along with the default HTTP documents.
+func fileLoader() Loader {
+	return SchemeLoader{"file": FileLoader{}, "http": defaultLoader, "https": defaultLoader}
+}
+
+// fileURI returns the file:// URI of the canonical path of a file.
+func fileURI(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	slashed := filepath.ToSlash(abs)
+	if !strings.HasPrefix(slashed, "/") {
+		// A Windows path like C:/schemas.
+		slashed = "/" + slashed
+	}
+	u := url.URL{Scheme: "file", Path: slashed}
+	return u.String(), nil
 }
 
-func (s *Schema) Parse(schemaBytes io.Reader, loadExternalSchemas bool) error {
-	s.ParseWithoutRefs(schemaBytes)
-	s.ResolveRefs(loadExternalSchemas)
+func (s *Schema) Parse(schemaBytes io.Reader, loadExternalSchemas bool, opts ...ParseOption) error {
+	if err := s.ParseWithoutRefs(schemaBytes, opts...); err != nil {
+		return err
+	}
+	c := newParseConfig(opts)
+	res := &resolver{loadExternal: loadExternalSchemas, loader: c.loader, baseURI: c.baseURI, opts: opts}
+	if unresolved := s.resolveRefs(res); len(unresolved) > 0 && !c.lenientRefs {
+		return unresolved
+	}
 	return nil
 }
 
-func (s *Schema) ParseWithoutRefs(schemaBytes io.Reader) error {
+func (s *Schema) ParseWithoutRefs(schemaBytes io.Reader, opts ...ParseOption) error {
 	if s.Cache == nil {
 		s.Cache = make(map[string]*Schema)
 	}
-	if err := json.NewDecoder(schemaBytes).Decode(&s); err != nil {
+	c := newParseConfig(opts)
+	if !c.validateAgainstMetaSchema {
+		if err := json.NewDecoder(schemaBytes).Decode(s); err != nil {
+			return err
+		}
+		return s.compileDocument(c)
+	}
+	b, err := ioutil.ReadAll(schemaBytes)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
 		return err
 	}
+	if err := s.compileDocument(c); err != nil {
+		return err
+	}
+	return validateAgainstMetaSchema(s.draft, b)
+}
+
+// compileDocument compiles a schema that is the root of a document.
+func (s *Schema) compileDocument(c *parseConfig) error {
+	s.compile(c.draft, nil, c, "")
+	walkResources(s, func(sch, resource *Schema) {
+		sch.document = s
+	})
+	if len(c.keywordErrors) > 0 {
+		return c.keywordErrors
+	}
+	return nil
+}
+
+// validateAgainstMetaSchema validates a schema document against the meta-schema
+// of draft d.
+func validateAgainstMetaSchema(d Draft, b []byte) error {
+	meta, err := metaSchema(d)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return err
+	}
+	if errs := meta.Validate(nil, doc); len(errs) > 0 {
+		return &MetaSchemaError{d, errs}
+	}
 	return nil
 }
 
 func (s *Schema) Validate(keypath []string, v interface{}) []ValidationError {
-	var valErrs []ValidationError
-	for _, n := range s.nodes {
-		valErrs = append(valErrs, n.Validator.Validate(keypath, v)...)
+	errs := s.evaluate(nil, keypath, v).Errors
+	moveSchemaPaths(errs, s.pointer, "")
+	return errs
+}
+
+// evaluate validates v and reports the properties and items of v that were
+// evaluated. If v is invalid, only the errors are reported, and s is added to
+// their trails.
+func (s *Schema) evaluate(scope Scope, keypath []string, v interface{}) Result {
+	r := s.evaluateKeywords(scope, keypath, v)
+	if len(r.Errors) > 0 {
+		location := instanceLocation(keypath)
+		for i := range r.Errors {
+			r.Errors[i].trail = append(r.Errors[i].trail, schemaFrame{s.pointer, s.absoluteLocation(s.pointer), location})
+		}
+	}
+	return r
+}
+
+func (s *Schema) evaluateKeywords(scope Scope, keypath []string, v interface{}) Result {
+	if s.boolean != nil && !*s.boolean {
+		return Result{Errors: []ValidationError{{Keypath: keypath, Code: CodeFalseSchema,
+			SchemaPath: s.pointer, AbsoluteKeywordLocation: s.absoluteLocation(s.pointer),
+			Description: "The schema is false, so no value is valid."}}}
+	}
+	if s.isResource {
+		scope = append(scope, s)
+	}
+	if n, ok := s.nodes["$ref"]; ok && s.draft < Draft2019 {
+		// Up to draft 7 "$ref" overrides the other keywords of the schema.
+		return s.locate("$ref", evaluate(n.Validator, scope, keypath, v))
+	}
+	var r Result
+	var unevaluatedCheckers []string
+	for key, n := range s.nodes {
+		if _, ok := n.Validator.(UnevaluatedChecker); ok {
+			unevaluatedCheckers = append(unevaluatedCheckers, key)
+			continue
+		}
+		r.merge(s.locate(key, evaluate(n.Validator, scope, keypath, v)))
+	}
+	var unevaluated Result
+	for _, key := range unevaluatedCheckers {
+		u := s.nodes[key].Validator.(UnevaluatedChecker)
+		unevaluated.merge(s.locate(key, u.EvaluateUnevaluated(scope, keypath, v, r)))
+	}
+	r.merge(unevaluated)
+	if len(r.Errors) > 0 {
+		return Result{Errors: r.Errors}
+	}
+	return r
+}
+
+// locate sets the location of the errors that the keyword key of s raised. The
+// errors of subschemas and referenced schemas were located by them.
+func (s *Schema) locate(key string, r Result) Result {
+	for i := range r.Errors {
+		e := &r.Errors[i]
+		if e.AbsoluteKeywordLocation != "" {
+			continue
+		}
+		keyword := e.Keyword
+		if keyword == "" {
+			keyword = key
+		}
+		e.SchemaPath = s.pointer + "/" + escapePointerToken(keyword)
+		e.AbsoluteKeywordLocation = s.absoluteLocation(e.SchemaPath)
+	}
+	return r
+}
+
+// absoluteLocation returns the URI of a JSON pointer into the document of s. It
+// is only a fragment if the document has no URI.
+func (s *Schema) absoluteLocation(pointer string) string {
+	doc := s.document
+	if doc == nil {
+		doc = s
+	}
+	uri := doc.id
+	if uri == "" || strings.HasPrefix(uri, "#") {
+		uri = doc.parentId
+	}
+	fragment := url.URL{Fragment: pointer}
+	if u, err := url.Parse(uri); err == nil {
+		u.Fragment, u.RawFragment = "", ""
+		return u.String() + "#" + fragment.EscapedFragment()
 	}
-	return valErrs
+	return "#" + fragment.EscapedFragment()
 }
 
+// UnmarshalJSON only records the keywords of the schema. They are turned into
+// validators by compile, once the draft of the schema is known.
 func (s *Schema) UnmarshalJSON(bts []byte) error {
+	// Since draft 6, true and false are valid schemas.
+	var b bool
+	if err := json.Unmarshal(bts, &b); err == nil {
+		s.boolean = &b
+		return nil
+	}
 	schemaMap := make(map[string]json.RawMessage)
 	if err := json.Unmarshal(bts, &schemaMap); err != nil {
 		return err
 	}
-	s.nodes = make(map[string]Node, len(schemaMap))
-	for schemaKey, schemaValue := range schemaMap {
-		var n Node
-		if typ, ok := validatorMap[schemaKey]; ok {
-			n.Validator = reflect.New(typ).Interface().(Validator)
-		} else {
-			// Even if we don't recognize a schema key, we unmarshal its contents anyway
-			// because it might contain embedded schemas referenced elsewhere in the document.
-			n.Validator = new(other)
-		}
-		decoder := json.NewDecoder(bytes.NewReader(schemaValue))
-		decoder.UseNumber()
-		if err := decoder.Decode(n.Validator); err != nil {
-			if schemaKey == "id" {
-				s.id = string(schemaValue)
-				s.id = strings.TrimPrefix(s.id, "\"")
-				s.id = strings.TrimSuffix(s.id, "\"")
-			}
+	s.raw = schemaMap
+	return nil
+}
+
+// compile creates the validators of a schema and its subschemas from their keywords.
+// The schema is compiled according to the draft it declares with "$schema", or to
+// the draft d inherited from its parent if it doesn't declare one.
+//
+// resource is the schema resource that contains s, or nil if s is the root of
+// a document. pointer is the JSON pointer to s in its document. Errors in the
+// keywords are added to c.
+func (s *Schema) compile(d Draft, resource *Schema, c *parseConfig, pointer string) {
+	if v, ok := s.raw["$schema"]; ok {
+		if schemaDraft, ok := draftFromSchemaKeyword(v); ok {
+			d = schemaDraft
+		} else if c.disallowUnknownDialects {
+			c.keywordErrors = append(c.keywordErrors,
+				KeywordError{pointer + "/$schema", fmt.Errorf("unknown $schema %s", v)})
+		}
+	}
+	s.draft = d
+	s.pointer = pointer
+	if s.boolean != nil && d < Draft6 {
+		// Before draft 6 a boolean isn't a schema, so it is ignored like the
+		// other malformed keywords.
+		if c.strict {
+			c.keywordErrors = append(c.keywordErrors,
+				KeywordError{pointer, fmt.Errorf("boolean schemas require draft 6 or later")})
+		}
+		s.boolean = nil
+	}
+	// Up to draft 7 an id next to "$ref" is ignored like the other keywords.
+	_, hasRef := s.raw["$ref"]
+	ignoreId := hasRef && d < Draft2019
+	if v, ok := s.raw[d.idKeyword()]; ok && !ignoreId {
+		if err := json.Unmarshal(v, &s.id); err == nil && !strings.HasPrefix(s.id, "#") {
+			resource = nil
+		}
+	}
+	if resource == nil {
+		s.isResource = true
+		resource = s
+	}
+	s.nodes = make(map[string]Node, len(s.raw))
+	for schemaKey, schemaValue := range s.raw {
+		keywordPointer := pointer + "/" + escapePointerToken(schemaKey)
+		var err error
+		switch {
+		case schemaKey == "$schema":
 			continue
+		case schemaKey == d.idKeyword() && !ignoreId:
+			err = json.Unmarshal(schemaValue, &s.id)
+		case schemaKey == "$anchor" && d >= Draft2019:
+			err = json.Unmarshal(schemaValue, &s.anchor)
+		case schemaKey == "$recursiveAnchor" && d == Draft2019:
+			err = json.Unmarshal(schemaValue, &s.recursiveAnchor)
+		case schemaKey == "$dynamicAnchor" && d >= Draft2020:
+			if err = json.Unmarshal(schemaValue, &s.dynamicAnchor); err == nil {
+				if resource.dynamicAnchors == nil {
+					resource.dynamicAnchors = make(map[string]*Schema)
+				}
+				resource.dynamicAnchors[s.dynamicAnchor] = s
+			}
+		default:
+			err = s.compileKeyword(schemaKey, schemaValue, d, resource, c, keywordPointer)
 		}
-		if v, ok := n.Validator.(SchemaEmbedder); ok {
-			n.EmbeddedSchemas = v.LinkEmbedded()
+		if err != nil && c.strict {
+			c.keywordErrors = append(c.keywordErrors, KeywordError{keywordPointer, err})
 		}
-		s.nodes[schemaKey] = n
 	}
 	// Make changes to a validator based on its neighbors, if appropriate.
 	for _, n := range s.nodes {
 		if v, ok := n.Validator.(SchemaSetter); ok {
-			v.SetSchema(schemaMap)
+			v.SetSchema(s.raw)
 		}
 		if v, ok := n.Validator.(NeighborChecker); ok {
 			v.CheckNeighbors(s.nodes)
 		}
 	}
+}
+
+// compileKeyword creates the validator of a keyword, and compiles the schemas
+// embedded in it. It returns an error if the value of the keyword is invalid.
+func (s *Schema) compileKeyword(key string, value json.RawMessage, d Draft, resource *Schema, c *parseConfig, pointer string) error {
+	var n Node
+	typ, known := d.validators()[key]
+	if known {
+		n.Validator = reflect.New(typ).Interface().(Validator)
+	} else {
+		// Even if we don't recognize a schema key, we unmarshal its contents anyway
+		// because it might contain embedded schemas referenced elsewhere in the document.
+		n.Validator = new(other)
+		// The value of an unknown keyword isn't necessarily a schema, so its
+		// errors aren't reported.
+		c = &parseConfig{draft: c.draft}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	if err := decoder.Decode(n.Validator); err != nil {
+		if !known {
+			return nil
+		}
+		return err
+	}
+	if v, ok := n.Validator.(DraftSetter); ok {
+		v.SetDraft(d)
+	}
+	if v, ok := n.Validator.(entryChecker); ok && c.strict {
+		invalid := v.invalidEntries()
+		names := make([]string, 0, len(invalid))
+		for name := range invalid {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			c.keywordErrors = append(c.keywordErrors, KeywordError{pointer + "/" + escapePointerToken(name), invalid[name]})
+		}
+	}
+	if l, ok := n.Validator.(refLinker); ok {
+		l.reference().pointer = pointer
+	}
+	if v, ok := n.Validator.(SchemaEmbedder); ok {
+		n.EmbeddedSchemas = v.LinkEmbedded()
+		for name, sch := range n.EmbeddedSchemas {
+			schemaPointer := pointer
+			if name != "" {
+				schemaPointer += "/" + escapePointerToken(name)
+			}
+			sch.compile(d, resource, c, schemaPointer)
+		}
+	}
+	s.nodes[key] = n
 	return nil
 }
 
@@ -143,7 +549,27 @@ type Schema struct {
 	parentId string
 	nodes    map[string]Node
 	resolved bool
-	Cache    map[string]*Schema
+	draft    Draft
+	// The plain name fragment identifying the schema since draft 2019-09.
+	anchor string
+	// Set if the schema is the root of a document or has an id of its own. Schema
+	// resources make up the dynamic scope of an evaluation.
+	isResource bool
+	// Set by "$recursiveAnchor" in draft 2019-09.
+	recursiveAnchor bool
+	// The name declared with "$dynamicAnchor" since draft 2020-12, and, for a
+	// schema resource, the schemas declaring one within the resource by name.
+	dynamicAnchor  string
+	dynamicAnchors map[string]*Schema
+	// The keywords of the schema as they were unmarshaled.
+	raw map[string]json.RawMessage
+	// A JSON pointer to the schema in its document, and the root schema of the
+	// document.
+	pointer  string
+	document *Schema
+	// Set if the schema is a boolean schema instead of an object.
+	boolean *bool
+	Cache   map[string]*Schema
 }
 
 type Node struct {
@@ -170,12 +596,122 @@ type NeighborChecker interface {
 }
 
 type ValidationError struct {
-	Keypath     []string
+	Keypath []string
+	// A message describing the error, written by the validator that raised it.
+	// Code and Params hold the same information in a structured form.
 	Description string
+	// The innermost reference (such as "$ref") that was followed to the schema
+	// that raised the error, if any.
+	Ref string
+	// A JSON pointer to the keyword that raised the error, from the schema
+	// being validated. The references that were followed are part of the path,
+	// like "/properties/a/$ref/maxLength".
+	SchemaPath string
+	// The URI of the keyword that raised the error in its document, after the
+	// references were followed, like
+	// "https://example.com/defs.json#/definitions/name/maxLength".
+	AbsoluteKeywordLocation string
+	// The schema keyword that raised the error, like "maxLength". It is empty
+	// for a false schema.
+	Keyword string
+	// The kind of error, one of the Code constants.
+	Code string
+	// The values the error depends on, like the "limit" and "actual" length of a
+	// string that is too long. The parameters of each code are listed with it.
+	Params map[string]interface{}
+	// The errors of each schema of the "anyOf" or "oneOf" keyword that raised
+	// the error, by index. The schemas that are valid have none.
+	BranchErrors [][]ValidationError
+
+	// The schemas that were evaluated on the way to the error, from the
+	// innermost to the outermost. The output formats are built from it.
+	trail []schemaFrame
+}
+
+// A schemaFrame is the evaluation of a schema at an instance location.
+type schemaFrame struct {
+	// A JSON pointer to the schema, which is moved like SchemaPath when
+	// references are followed.
+	path string
+	// The URI of the schema in its document.
+	absoluteLocation string
+	instanceLocation string
+}
+
+// The codes of validation errors. The parameters of the errors are listed
+// after each code.
+const (
+	CodeFalseSchema           = "false_schema"
+	CodeAnyOf                 = "any_of"
+	CodeOneOf                 = "one_of" // matched: the number of valid schemas, indexes: their indexes
+	CodeNot                   = "not"
+	CodeConst                 = "const" // value
+	CodeEnum                  = "enum"  // values
+	CodeType                  = "type"  // types, actual: the type of the data
+	CodeDisallow              = "disallow"
+	CodeContainsTooFew        = "contains_too_few"       // limit, actual: the number of valid items
+	CodeContainsTooMany       = "contains_too_many"      // limit, actual
+	CodeMaxItems              = "max_items"              // limit, actual
+	CodeMinItems              = "min_items"              // limit, actual
+	CodeAdditionalItems       = "additional_items"       // index
+	CodeUnevaluatedItems      = "unevaluated_items"      // index
+	CodeUniqueItems           = "unique_items"           // duplicates: pairs of indexes
+	CodeInvalidNumber         = "invalid_number"         // error
+	CodeMaximum               = "maximum"                // limit
+	CodeExclusiveMaximum      = "exclusive_maximum"      // limit
+	CodeMinimum               = "minimum"                // limit
+	CodeExclusiveMinimum      = "exclusive_minimum"      // limit
+	CodeMultipleOf            = "multiple_of"            // divisor
+	CodeAdditionalProperties  = "additional_properties"  // property
+	CodeUnevaluatedProperties = "unevaluated_properties" // property
+	CodeRequired              = "required"               // property
+	CodeDependentRequired     = "dependent_required"     // property, requiredBy
+	CodeMaxProperties         = "max_properties"         // limit, actual
+	CodeMinProperties         = "min_properties"         // limit, actual
+	CodeMaxLength             = "max_length"             // limit, actual
+	CodeMinLength             = "min_length"             // limit, actual
+	CodePattern               = "pattern"                // pattern
+	CodeFormat                = "format"                 // format
+)
+
+// withKeyword sets the keyword of errors raised by a validator that another
+// keyword reuses, like exclusiveMaximum reusing maximum.
+func withKeyword(errs []ValidationError, keyword string) []ValidationError {
+	for i := range errs {
+		errs[i].Keyword = keyword
+	}
+	return errs
+}
+
+// A KeywordError is an invalid keyword in a schema.
+type KeywordError struct {
+	// A JSON pointer to the keyword in the schema document.
+	Pointer string
+	Err     error
+}
+
+func (e KeywordError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pointer, e.Err)
+}
+
+// KeywordErrors is returned by the parse functions if keywords of a schema are
+// invalid. See Strict and DisallowUnknownDialects.
+type KeywordErrors []KeywordError
+
+func (e KeywordErrors) Error() string {
+	descriptions := make([]string, len(e))
+	for i, err := range e {
+		descriptions[i] = err.Error()
+	}
+	return "invalid keywords: " + strings.Join(descriptions, "; ")
 }
 
 func (e *ValidationError) JSONPointer() string {
-	return jsonpointer.NewJSONPointerFromTokens(&e.Keypath).String()
+	return instanceLocation(e.Keypath)
+}
+
+func instanceLocation(keypath []string) string {
+	return jsonpointer.NewJSONPointerFromTokens(&keypath).String()
 }
 
 func (e *ValidationError) DotNotation() string {
@@ -5,16 +5,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
-var notSupported = map[string]struct{}{"uniqueItems.json": {}}
+var notSupported = map[string]struct{}{}
 
 func TestJSONPointerKeypath(t *testing.T) {
 	keypath := []string{"foo", "bar", "10", "baz"}
-	err := &ValidationError{keypath, ""}
+	err := &ValidationError{Keypath: keypath}
 	str := err.JSONPointer()
 	expectedStr := "/foo/bar/10/baz"
 	if str != expectedStr {
@@ -24,7 +32,7 @@ func TestJSONPointerKeypath(t *testing.T) {
 
 func TestDotNotationKeypath(t *testing.T) {
 	keypath := []string{"foo", "bar", "10", "baz"}
-	err := &ValidationError{keypath, ""}
+	err := &ValidationError{Keypath: keypath}
 	str := err.DotNotation()
 	expectedStr := "foo.bar.10.baz"
 	if str != expectedStr {
@@ -32,10 +40,806 @@ func TestDotNotationKeypath(t *testing.T) {
 	}
 }
 
+func TestDraft4Keywords(t *testing.T) {
+	for _, tt := range []struct {
+		schema, data string
+		errors       int
+	}{
+		{`{"minimum": 2}`, `2`, 0},
+		{`{"minimum": 2}`, `1`, 1},
+		{`{"minimum": 2, "exclusiveMinimum": true}`, `2`, 1},
+		{`{"minimum": 2, "exclusiveMinimum": true}`, `3`, 0},
+		{`{"maximum": 2, "exclusiveMaximum": true}`, `2`, 1},
+		{`{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1, "b": 2, "c": 3}`, 2},
+	} {
+		s, err := Parse(bytes.NewReader([]byte(tt.schema)), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data interface{}
+		decoder := json.NewDecoder(bytes.NewReader([]byte(tt.data)))
+		decoder.UseNumber()
+		if err := decoder.Decode(&data); err != nil {
+			t.Fatal(err)
+		}
+		if errs := s.Validate(nil, data); len(errs) != tt.errors {
+			t.Errorf("%s: expected %d errors for %s, got %v", tt.schema, tt.errors, tt.data, errs)
+		}
+	}
+}
+
+func TestParseDialect(t *testing.T) {
+	// Numeric exclusiveMaximum is a draft 6 keyword.
+	schema := []byte(`{"exclusiveMaximum": 5}`)
+	s, err := Parse(bytes.NewReader(schema), false, WithDraft(Draft6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Validate(nil, json.Number("5"))) == 0 {
+		t.Error("Expected the forced draft to be used.")
+	}
+
+	// A declared draft takes precedence over the forced one.
+	schema = []byte(`{"$schema": "http://json-schema.org/draft-04/schema#", "exclusiveMaximum": 5}`)
+	s, err = Parse(bytes.NewReader(schema), false, WithDraft(Draft6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Validate(nil, json.Number("5"))) != 0 {
+		t.Error("Expected the declared draft to be used.")
+	}
+
+	schema = []byte(`{"$schema": "http://example.com/unknown#"}`)
+	if _, err := Parse(bytes.NewReader(schema), false); err != nil {
+		t.Errorf("Expected an unknown dialect to be ignored, got %s", err)
+	}
+	if _, err := Parse(bytes.NewReader(schema), false, DisallowUnknownDialects()); err == nil {
+		t.Error("Expected an error for an unknown dialect.")
+	}
+}
+
+func TestValidateAgainstMetaSchema(t *testing.T) {
+	schema := []byte(`{"properties": {"name": {"minLength": "ten"}}}`)
+	if _, err := Parse(bytes.NewReader(schema), false); err != nil {
+		t.Errorf("Expected the schema to be parsed without the option, got %s", err)
+	}
+	_, err := Parse(bytes.NewReader(schema), false, ValidateAgainstMetaSchema())
+	metaErr, ok := err.(*MetaSchemaError)
+	if !ok {
+		t.Fatalf("Expected a *MetaSchemaError, got %v", err)
+	}
+	if len(metaErr.Errors) != 1 || metaErr.Errors[0].JSONPointer() != "/properties/name/minLength" {
+		t.Errorf("Expected one error at /properties/name/minLength, got %v", metaErr.Errors)
+	}
+
+	schema = []byte(`{"$schema": "https://json-schema.org/draft/2020-12/schema", "prefixItems": [{"type": "string"}]}`)
+	if _, err := Parse(bytes.NewReader(schema), false, ValidateAgainstMetaSchema()); err != nil {
+		t.Errorf("Expected a valid schema, got %s", err)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	schema := []byte(`{"minLength": "ten", "properties": {"a/b": {"pattern": "("}, "c": {"maxProperties": -1}}}`)
+	if _, err := Parse(bytes.NewReader(schema), false); err != nil {
+		t.Errorf("Expected invalid keywords to be ignored, got %s", err)
+	}
+	_, err := Parse(bytes.NewReader(schema), false, Strict())
+	keywordErrs, ok := err.(KeywordErrors)
+	if !ok {
+		t.Fatalf("Expected KeywordErrors, got %v", err)
+	}
+	pointers := make(map[string]bool)
+	for _, e := range keywordErrs {
+		pointers[e.Pointer] = true
+	}
+	for _, p := range []string{"/minLength", "/properties/a~1b/pattern", "/properties/c/maxProperties"} {
+		if !pointers[p] {
+			t.Errorf("Expected an error at %s, got %v", p, keywordErrs)
+		}
+	}
+	if len(keywordErrs) != 3 {
+		t.Errorf("Expected 3 errors, got %v", keywordErrs)
+	}
+
+	// An invalid dependency doesn't invalidate the others, and a single
+	// property dependency is only valid in draft 3.
+	schema = []byte(`{"dependencies": {"a": ["b"], "c": 5, "d": "e"}}`)
+	for _, draft := range []Draft{Draft3, Draft4} {
+		s, err := Parse(bytes.NewReader(schema), false, WithDraft(draft))
+		if err != nil {
+			t.Fatal(err)
+		}
+		errs := s.Validate(nil, map[string]interface{}{"a": true, "d": true})
+		if expected := map[Draft]int{Draft3: 2, Draft4: 1}[draft]; len(errs) != expected {
+			t.Errorf("draft %d: expected %d errors, got %v", draft, expected, errs)
+		}
+	}
+	_, err = Parse(bytes.NewReader(schema), false, WithDraft(Draft4), Strict())
+	keywordErrs, _ = err.(KeywordErrors)
+	if len(keywordErrs) != 2 || keywordErrs[0].Pointer != "/dependencies/c" || keywordErrs[1].Pointer != "/dependencies/d" {
+		t.Errorf("Expected errors at /dependencies/c and /dependencies/d, got %v", err)
+	}
+
+	// Boolean schemas appeared in draft 6.
+	schema = []byte(`{"properties": {"a": false}}`)
+	s, err := Parse(bytes.NewReader(schema), false, WithDraft(Draft4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := s.Validate(nil, map[string]interface{}{"a": json.Number("1")}); len(errs) != 0 {
+		t.Errorf("Expected the boolean schema to be ignored, got %v", errs)
+	}
+	_, err = Parse(bytes.NewReader(schema), false, WithDraft(Draft4), Strict())
+	if keywordErrs, ok := err.(KeywordErrors); !ok || len(keywordErrs) != 1 || keywordErrs[0].Pointer != "/properties/a" {
+		t.Errorf("Expected an error at /properties/a, got %v", err)
+	}
+	if _, err := Parse(bytes.NewReader(schema), false, WithDraft(Draft6), Strict()); err != nil {
+		t.Errorf("Expected a boolean schema to be valid, got %s", err)
+	}
+}
+
+func TestUnresolvedRefs(t *testing.T) {
+	schema := []byte(`{"properties": {"a": {"$ref": "#/definitions/missing"}, "b": {"$ref": "http://example.com/b.json"}}}`)
+	_, err := Parse(bytes.NewReader(schema), false)
+	unresolved, ok := err.(UnresolvedRefs)
+	if !ok {
+		t.Fatalf("Expected UnresolvedRefs, got %v", err)
+	}
+	reasons := make(map[string]RefErrorReason)
+	for _, r := range unresolved {
+		reasons[r.Pointer] = r.Reason
+	}
+	if len(unresolved) != 2 || reasons["/properties/a"] != PointerNotFound || reasons["/properties/b"] != ExternalDisabled {
+		t.Errorf("Expected a missing pointer and a disabled external reference, got %v", unresolved)
+	}
+
+	if _, err := Parse(bytes.NewReader(schema), false, LenientRefs()); err != nil {
+		t.Errorf("Expected unresolved references to be ignored, got %s", err)
+	}
+	for _, ref := range []string{"other.json", "other.json#/definitions/a"} {
+		schema := []byte(`{"$ref": "` + ref + `"}`)
+		_, err := Parse(bytes.NewReader(schema), true)
+		unresolved, ok := err.(UnresolvedRefs)
+		if !ok || len(unresolved) != 1 || unresolved[0].Reason != NoBaseURI {
+			t.Errorf("%s: expected a reference without a base URI, got %v", ref, err)
+		}
+	}
+}
+
+func TestRefCycles(t *testing.T) {
+	for _, draft := range []Draft{Draft4, Draft2019} {
+		schema := []byte(`{"definitions": {"a": {"$ref": "#/definitions/b"}, "b": {"$ref": "#/definitions/a"}}, "$ref": "#/definitions/a"}`)
+		_, err := Parse(bytes.NewReader(schema), false, WithDraft(draft))
+		unresolved, ok := err.(UnresolvedRefs)
+		if !ok || len(unresolved) == 0 {
+			t.Fatalf("draft %d: expected UnresolvedRefs, got %v", draft, err)
+		}
+		for _, r := range unresolved {
+			if r.Reason != CircularRef {
+				t.Errorf("draft %d: expected a circular reference, got %s", draft, r)
+			}
+		}
+
+		// A cycle through an applicator that applies to the same instance.
+		schema = []byte(`{"definitions": {"a": {"allOf": [{"$ref": "#/definitions/a"}]}}, "properties": {"x": {"$ref": "#/definitions/a"}}}`)
+		_, err = Parse(bytes.NewReader(schema), false, WithDraft(draft))
+		unresolved, ok = err.(UnresolvedRefs)
+		if !ok || len(unresolved) != 1 || unresolved[0].Reason != CircularRef {
+			t.Errorf("draft %d: expected a circular reference, got %v", draft, err)
+		}
+
+		// A tree refers to itself through its children, which isn't a cycle.
+		tree := []byte(`{"type": "object", "properties": {"value": {"type": "integer"}, "children": {"type": "array", "items": {"$ref": "#"}}}}`)
+		s, err := Parse(bytes.NewReader(tree), false, WithDraft(draft))
+		if err != nil {
+			t.Fatalf("draft %d: %s", draft, err)
+		}
+		var data interface{}
+		json.Unmarshal([]byte(`{"value": 1, "children": [{"value": 2, "children": [{"value": "3"}]}]}`), &data)
+		if len(s.Validate(nil, data)) == 0 {
+			t.Errorf("draft %d: expected the tree to fail bad data.", draft)
+		}
+	}
+}
+
+func TestFollowedRef(t *testing.T) {
+	for _, tt := range []struct {
+		draft  Draft
+		errors int
+	}{
+		// Up to draft 7 the neighbors of "$ref" are ignored.
+		{Draft7, 1},
+		{Draft2019, 2},
+	} {
+		schema := []byte(`{"definitions": {"a": {"type": "integer"}}, "properties": {"x": {"$ref": "#/definitions/a", "minLength": 2}}}`)
+		s, err := Parse(bytes.NewReader(schema), false, WithDraft(tt.draft))
+		if err != nil {
+			t.Fatal(err)
+		}
+		errs := s.Validate(nil, map[string]interface{}{"x": "1"})
+		if len(errs) != tt.errors {
+			t.Fatalf("draft %d: expected %d errors, got %v", tt.draft, tt.errors, errs)
+		}
+		var followed int
+		for _, e := range errs {
+			if e.Ref == "#/definitions/a" {
+				followed++
+			}
+		}
+		if followed != 1 {
+			t.Errorf("draft %d: expected the followed $ref to be recorded once, got %+v", tt.draft, errs)
+		}
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	for _, tt := range []struct {
+		schema  string
+		draft   Draft
+		data    interface{}
+		keyword string
+		code    string
+		params  map[string]interface{}
+	}{
+		{`{"maxLength": 2}`, Draft7, "abc", "maxLength", CodeMaxLength, map[string]interface{}{"limit": 2, "actual": 3}},
+		{`{"required": ["a"]}`, Draft7, map[string]interface{}{}, "required", CodeRequired, map[string]interface{}{"property": "a"}},
+		{`{"type": ["string", "null"]}`, Draft7, true, "type", CodeType, map[string]interface{}{"types": []string{"null", "string"}, "actual": "boolean"}},
+		{`{"exclusiveMaximum": 1}`, Draft7, json.Number("1"), "exclusiveMaximum", CodeExclusiveMaximum, map[string]interface{}{"limit": json.Number("1")}},
+		{`{"divisibleBy": 2}`, Draft3, json.Number("3"), "divisibleBy", CodeMultipleOf, map[string]interface{}{"divisor": json.Number("2")}},
+		{`{"properties": {"a": {"required": true}}}`, Draft3, map[string]interface{}{}, "required", CodeRequired, map[string]interface{}{"property": "a"}},
+		{`{"dependencies": {"a": ["b"]}}`, Draft7, map[string]interface{}{"a": true}, "dependencies", CodeDependentRequired, map[string]interface{}{"property": "b", "requiredBy": "a"}},
+		{`false`, Draft7, nil, "", CodeFalseSchema, nil},
+		{`{"uniqueItems": true}`, Draft4, []interface{}{json.Number("1"), json.Number("1.0"), json.Number("2"), json.Number("1")},
+			"uniqueItems", CodeUniqueItems, map[string]interface{}{"duplicates": [][2]int{{0, 1}, {0, 3}, {1, 3}}}},
+		{`{"uniqueItems": true}`, Draft4, []interface{}{
+			map[string]interface{}{"a": []interface{}{json.Number("1"), "x"}},
+			map[string]interface{}{"a": []interface{}{true, "x"}},
+			map[string]interface{}{"a": []interface{}{json.Number("1.0"), "x"}},
+			[]interface{}{map[string]interface{}{}},
+			[]interface{}{map[string]interface{}{}},
+		}, "uniqueItems", CodeUniqueItems, map[string]interface{}{"duplicates": [][2]int{{0, 2}, {3, 4}}}},
+	} {
+		s, err := Parse(strings.NewReader(tt.schema), false, WithDraft(tt.draft))
+		if err != nil {
+			t.Fatal(err)
+		}
+		errs := s.Validate(nil, tt.data)
+		if len(errs) != 1 {
+			t.Fatalf("%s: expected 1 error, got %v", tt.schema, errs)
+		}
+		e := errs[0]
+		if e.Keyword != tt.keyword || e.Code != tt.code || !reflect.DeepEqual(e.Params, tt.params) || e.Description == "" {
+			t.Errorf("%s: unexpected error %+v", tt.schema, e)
+		}
+	}
+}
+
+func TestIntegerType(t *testing.T) {
+	for _, tt := range []struct {
+		draft Draft
+		data  json.Number
+		valid bool
+	}{
+		{Draft4, "1", true},
+		{Draft4, "1.0", false},
+		{Draft4, "1e2", false},
+		{Draft4, "1e-5", false},
+		{Draft6, "1.0", true},
+		{Draft6, "1e2", true},
+		{Draft6, "1E2", true},
+		{Draft6, "1e-5", false},
+		{Draft6, "1.5", false},
+	} {
+		s, err := Parse(strings.NewReader(`{"type": "integer"}`), false, WithDraft(tt.draft))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if valid := len(s.Validate(nil, tt.data)) == 0; valid != tt.valid {
+			t.Errorf("draft %d: expected %s to be valid: %t, got %t", tt.draft, tt.data, tt.valid, valid)
+		}
+	}
+}
+
+func TestSchemaPath(t *testing.T) {
+	schema := []byte(`{
+		"definitions": {"short": {"maxLength": 2}},
+		"properties": {
+			"a": {"allOf": [true, {"$ref": "#/definitions/short"}]},
+			"b": {"$ref": "http://example.com/defs.json#/definitions/int"}
+		}
+	}`)
+	loader := MapLoader{"http://example.com/defs.json": []byte(`{"definitions": {"int": {"type": "integer"}}}`)}
+	s, err := Parse(bytes.NewReader(schema), true, WithLoader(loader), WithBaseURI("http://example.com/root.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		data                    map[string]interface{}
+		schemaPath, absLocation string
+	}{
+		{map[string]interface{}{"a": "abc"}, "/properties/a/allOf/1/$ref/maxLength", "http://example.com/root.json#/definitions/short/maxLength"},
+		{map[string]interface{}{"b": "abc"}, "/properties/b/$ref/type", "http://example.com/defs.json#/definitions/int/type"},
+	} {
+		errs := s.Validate(nil, tt.data)
+		if len(errs) != 1 || errs[0].SchemaPath != tt.schemaPath || errs[0].AbsoluteKeywordLocation != tt.absLocation {
+			t.Errorf("expected an error at %s (%s), got %+v", tt.schemaPath, tt.absLocation, errs)
+		}
+	}
+
+	// The path is relative to the schema being validated.
+	errs := s.Properties()["a"].Validate(nil, "abc")
+	if len(errs) != 1 || errs[0].SchemaPath != "/allOf/1/$ref/maxLength" {
+		t.Errorf("expected an error at /allOf/1/$ref/maxLength, got %+v", errs)
+	}
+}
+
+func TestBranchErrors(t *testing.T) {
+	schema := []byte(`{
+		"definitions": {"small": {"maximum": 1}},
+		"properties": {
+			"a": {"anyOf": [{"$ref": "#/definitions/small"}, {"type": "string"}]},
+			"b": {"oneOf": [{"type": "integer"}, {"type": "string"}, {"minimum": 0}]}
+		}
+	}`)
+	s, err := Parse(bytes.NewReader(schema), false, WithDraft(Draft7))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := s.Validate(nil, map[string]interface{}{"a": json.Number("2")})
+	if len(errs) != 1 || len(errs[0].BranchErrors) != 2 {
+		t.Fatalf("expected an anyOf error with 2 branches, got %+v", errs)
+	}
+	for i, path := range []string{"/properties/a/anyOf/0/$ref/maximum", "/properties/a/anyOf/1/type"} {
+		branch := errs[0].BranchErrors[i]
+		if len(branch) != 1 || branch[0].SchemaPath != path {
+			t.Errorf("expected an error at %s in branch %d, got %+v", path, i, branch)
+		}
+	}
+
+	errs = s.Validate(nil, map[string]interface{}{"b": json.Number("1")})
+	if len(errs) != 1 || !reflect.DeepEqual(errs[0].Params["indexes"], []int{0, 2}) {
+		t.Fatalf("expected a oneOf error matching branches 0 and 2, got %+v", errs)
+	}
+	if branches := errs[0].BranchErrors; len(branches) != 3 || branches[0] != nil || len(branches[1]) != 1 || branches[2] != nil {
+		t.Errorf("expected an error in branch 1 only, got %+v", branches)
+	}
+}
+
+func TestBestMatch(t *testing.T) {
+	for _, tt := range []struct {
+		schema     string
+		data       interface{}
+		schemaPath string
+	}{
+		{`{"properties": {"a": {"properties": {"b": {"type": "string"}}}}, "required": ["c"]}`,
+			map[string]interface{}{"a": map[string]interface{}{"b": true}}, "/properties/a/properties/b/type"},
+		// The branch of the matching "kind" is picked, even though the string
+		// branch has fewer errors.
+		{`{"oneOf": [
+			{"properties": {"kind": {"const": "circle"}, "radius": {"type": "number"}}, "required": ["radius"]},
+			{"properties": {"kind": {"const": "square"}, "side": {"type": "number"}}, "required": ["side"]},
+			{"type": "string"}
+		]}`, map[string]interface{}{"kind": "square", "side": "big"}, "/oneOf/1/properties/side/type"},
+		{`{"anyOf": [{"required": ["a", "b"]}, {"required": ["c"]}]}`, map[string]interface{}{}, "/anyOf/1/required"},
+		{`{"oneOf": [{"type": "integer"}, {"minimum": 0}]}`, json.Number("1"), "/oneOf"},
+	} {
+		s, err := Parse(strings.NewReader(tt.schema), false, WithDraft(Draft7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		best := BestMatch(s.Validate(nil, tt.data))
+		if best == nil || best.SchemaPath != tt.schemaPath {
+			t.Errorf("%s: expected the error at %s, got %+v", tt.schema, tt.schemaPath, best)
+		}
+	}
+	if BestMatch(nil) != nil {
+		t.Error("expected no best match without errors")
+	}
+}
+
+func TestOutput(t *testing.T) {
+	schema := []byte(`{"properties": {"a": {"allOf": [{"maxLength": 1}, {"pattern": "^x"}]}}}`)
+	s, err := Parse(bytes.NewReader(schema), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxLength := `{"valid": false, "keywordLocation": "/properties/a/allOf/0/maxLength", "instanceLocation": "/a", "error": "String length must be shorter than 1 characters."}`
+	pattern := `{"valid": false, "keywordLocation": "/properties/a/allOf/1/pattern", "instanceLocation": "/a", "error": "String must match the pattern: \"^x\"."}`
+	for _, tt := range []struct {
+		format OutputFormat
+		data   interface{}
+		output string
+	}{
+		{OutputFlag, map[string]interface{}{"a": "x"}, `{"valid": true}`},
+		{OutputFlag, map[string]interface{}{"a": "abc"}, `{"valid": false}`},
+		{OutputBasic, map[string]interface{}{"a": "abc"}, `{"valid": false, "errors": [` + maxLength + `, ` + pattern + `]}`},
+		{OutputDetailed, map[string]interface{}{"a": "x"}, `{"valid": true, "keywordLocation": "", "instanceLocation": ""}`},
+		{OutputDetailed, map[string]interface{}{"a": "abc"}, `{"valid": false, "keywordLocation": "", "instanceLocation": "", "errors": [
+			{"valid": false, "keywordLocation": "/properties/a/allOf", "instanceLocation": "/a", "errors": [` + maxLength + `, ` + pattern + `]}]}`},
+		{OutputVerbose, map[string]interface{}{"a": "z"}, `{"valid": false, "keywordLocation": "", "instanceLocation": "", "errors": [
+			{"valid": false, "keywordLocation": "/properties", "instanceLocation": "", "errors": [
+				{"valid": false, "keywordLocation": "/properties/a", "instanceLocation": "/a", "errors": [
+					{"valid": false, "keywordLocation": "/properties/a/allOf", "instanceLocation": "/a", "errors": [
+						{"valid": false, "keywordLocation": "/properties/a/allOf/1", "instanceLocation": "/a", "errors": [
+							{"valid": false, "keywordLocation": "/properties/a/allOf/1/pattern", "instanceLocation": "/a", "error": "String must match the pattern: \"^x\"."}]}]}]}]}]}`},
+	} {
+		b, err := json.Marshal(s.Output(tt.data, tt.format))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got, expected interface{}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tt.output), &expected); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("format %d: expected %s, got %s", tt.format, tt.output, b)
+		}
+	}
+}
+
+func TestLoaders(t *testing.T) {
+	schema := []byte(`{"properties": {"a": {"$ref": "http://example.com/a.json#/definitions/int"}, "b": {"$ref": "file:///schemas/b.json"}}}`)
+	loader := SchemeLoader{
+		"http": MapLoader{"http://example.com/a.json": []byte(`{"definitions": {"int": {"type": "integer"}}}`)},
+		"file": FSLoader{FS: fstest.MapFS{"schemas/b.json": {Data: []byte(`{"type": "string"}`)}}, BaseURI: "file:///"},
+	}
+	s, err := Parse(bytes.NewReader(schema), true, WithLoader(loader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Validate(nil, map[string]interface{}{"a": json.Number("1"), "b": "foo"})) != 0 {
+		t.Error("Expected the loaded schemas to validate good data.")
+	}
+	if len(s.Validate(nil, map[string]interface{}{"a": "1", "b": json.Number("1")})) != 2 {
+		t.Error("Expected the loaded schemas to fail bad data.")
+	}
+}
+
+func TestHTTPLoader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"type": "integer"}`))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	for _, tt := range []struct {
+		loader HTTPLoader
+		ok     bool
+	}{
+		{HTTPLoader{}, true},
+		{HTTPLoader{AllowedHosts: []string{serverURL.Hostname()}, MaxBodySize: 100, Timeout: time.Second}, true},
+		{HTTPLoader{AllowedHosts: []string{"example.com"}}, false},
+		{HTTPLoader{MaxBodySize: 10}, false},
+	} {
+		_, err := tt.loader.Load(server.URL + "/schema.json")
+		if tt.ok && err != nil {
+			t.Errorf("%+v: expected the document to be loaded, got %s", tt.loader, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("%+v: expected the document to be refused", tt.loader)
+		}
+	}
+
+	// An allowed host can't redirect to a host that isn't.
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://localhost:"+serverURL.Port()+"/schema.json", http.StatusFound)
+	}))
+	defer redirect.Close()
+	redirectURL, _ := url.Parse(redirect.URL)
+	loader := HTTPLoader{AllowedHosts: []string{redirectURL.Hostname()}}
+	if _, err := loader.Load(redirect.URL + "/schema.json"); err == nil {
+		t.Error("Expected the redirect to a host that isn't allowed to be refused.")
+	}
+	loader.AllowedHosts = append(loader.AllowedHosts, "localhost")
+	if _, err := loader.Load(redirect.URL + "/schema.json"); err != nil {
+		t.Errorf("Expected the redirect to an allowed host to be followed, got %s", err)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"order.json":          `{"properties": {"shipping": {"$ref": "common/address.json#/definitions/line"}}}`,
+		"common/address.json": `{"definitions": {"line": {"$ref": "string.json"}}}`,
+		"common/string.json":  `{"type": "string"}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := ParseFile(filepath.Join(dir, "order.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Validate(nil, map[string]interface{}{"shipping": "1 Main Street"})) != 0 {
+		t.Error("Expected the referenced files to validate good data.")
+	}
+	if len(s.Validate(nil, map[string]interface{}{"shipping": json.Number("1")})) == 0 {
+		t.Error("Expected the referenced files to fail bad data.")
+	}
+	uri, err := fileURI(filepath.Join(dir, "common", "address.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Cache[uri]; !ok {
+		t.Errorf("Expected %s to be cached.", uri)
+	}
+}
+
+func TestCompiler(t *testing.T) {
+	c := NewCompiler(WithDraft(Draft7))
+	resources := map[string]string{
+		"https://example.com/order.json":          `{"properties": {"shipping": {"$ref": "common/address.json"}}}`,
+		"https://example.com/common/address.json": `{"definitions": {"line": {"type": "string"}}, "properties": {"line1": {"$ref": "#/definitions/line"}}}`,
+	}
+	for uri, doc := range resources {
+		if err := c.AddResource(uri, strings.NewReader(doc)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.AddResource("https://example.com/order.json", strings.NewReader(`{}`)); err == nil {
+		t.Error("Expected an error for a resource added twice.")
+	}
+
+	var wg sync.WaitGroup
+	schemas := make([]*Schema, 10)
+	for i := range schemas {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s, err := c.Compile("https://example.com/order.json")
+			if err != nil {
+				t.Error(err)
+			}
+			schemas[i] = s
+		}(i)
+	}
+	wg.Wait()
+	for _, s := range schemas {
+		if s != schemas[0] {
+			t.Fatal("Expected the document to be compiled once.")
+		}
+	}
+	bad := map[string]interface{}{"shipping": map[string]interface{}{"line1": json.Number("1")}}
+	if len(schemas[0].Validate(nil, bad)) == 0 {
+		t.Error("Expected the referenced resource to fail bad data.")
+	}
+
+	line, err := c.Compile("https://example.com/common/address.json#/definitions/line")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(line.Validate(nil, json.Number("1"))) == 0 {
+		t.Error("Expected the schema at the fragment to be compiled.")
+	}
+	if _, err := c.Compile("https://example.com/missing.json"); err == nil {
+		t.Error("Expected an error for a resource that wasn't added.")
+	}
+}
+
+func TestBundle(t *testing.T) {
+	schema := []byte(`{"properties": {"a": {"$ref": "a.json#/definitions/int"}, "b": {"$ref": "b.json"}}}`)
+	loader := MapLoader{
+		"http://example.com/a.json": []byte(`{"definitions": {"int": {"allOf": [{"type": "integer"}, {"$ref": "b.json#/definitions/small"}]}}}`),
+		"http://example.com/b.json": []byte(`{"type": "string", "definitions": {"small": {"maximum": 10}}}`),
+	}
+	bundle, err := Bundle(bytes.NewReader(schema), "http://example.com/root.json", WithLoader(loader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tree struct {
+		Properties  map[string]map[string]string
+		Definitions map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(bundle, &tree); err != nil {
+		t.Fatal(err)
+	}
+	if ref := tree.Properties["a"]["$ref"]; ref != "#/definitions/http:~1~1example.com~1a.json/definitions/int" {
+		t.Errorf("Expected the reference to be rewritten, got %s", ref)
+	}
+	if len(tree.Definitions) != 2 {
+		t.Errorf("Expected the documents to be bundled by URI, got %s", bundle)
+	}
+
+	// The bundle doesn't refer to external documents.
+	s, err := Parse(bytes.NewReader(bundle), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Validate(nil, map[string]interface{}{"a": json.Number("1"), "b": "foo"})) != 0 {
+		t.Error("Expected the bundle to validate good data.")
+	}
+	if len(s.Validate(nil, map[string]interface{}{"a": json.Number("11"), "b": json.Number("1")})) != 2 {
+		t.Error("Expected the bundle to fail bad data.")
+	}
+
+	// Up to draft 7 the id of a document whose root has "$ref" is ignored.
+	schema = []byte(`{"properties": {"c": {"$ref": "x/d.json"}}}`)
+	loader = MapLoader{
+		"http://example.com/x/d.json": []byte(`{"$ref": "#/definitions/c", "definitions": {"c": {"$ref": "../y/c.json"}}}`),
+		"http://example.com/y/c.json": []byte(`{"type": "integer"}`),
+	}
+	bundle, err = Bundle(bytes.NewReader(schema), "http://example.com/root.json", WithLoader(loader), WithDraft(Draft7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = Parse(bytes.NewReader(bundle), false, WithDraft(Draft7))
+	if err != nil {
+		t.Fatalf("%s: %s", bundle, err)
+	}
+	if len(s.Validate(nil, map[string]interface{}{"c": json.Number("1")})) != 0 {
+		t.Error("Expected the bundle to validate good data.")
+	}
+	if len(s.Validate(nil, map[string]interface{}{"c": "foo"})) != 1 {
+		t.Error("Expected the bundle to fail bad data.")
+	}
+}
+
+func TestDereference(t *testing.T) {
+	for _, tt := range []struct {
+		draft    Draft
+		schema   string
+		expected string
+	}{
+		{
+			Draft4,
+			`{"definitions": {"a": {"$ref": "#/definitions/b"}, "b": {"type": "integer"}}, "properties": {"x": {"$ref": "#/definitions/a", "minimum": 9}}}`,
+			`{"definitions":{"a":{"type":"integer"},"b":{"type":"integer"}},"properties":{"x":{"type":"integer"}}}`,
+		},
+		{
+			Draft2019,
+			`{"$defs": {"a": {"type": "integer"}}, "properties": {"x": {"$ref": "#/$defs/a", "minimum": 9}, "y": {"$ref": "#/$defs/a"}}}`,
+			`{"$defs":{"a":{"type":"integer"}},"properties":{"x":{"allOf":[{"type":"integer"}],"minimum":9},"y":{"type":"integer"}}}`,
+		},
+		// Recursive references are kept.
+		{
+			Draft7,
+			`{"definitions": {"node": {"properties": {"next": {"$ref": "#/definitions/node"}}}}, "properties": {"head": {"$ref": "#/definitions/node"}}}`,
+			`{"definitions":{"node":{"properties":{"next":{"$ref":"#/definitions/node"}}}},"properties":{"head":{"properties":{"next":{"$ref":"#/properties/head"}}}}}`,
+		},
+		{
+			Draft7,
+			`{"$ref": "#/definitions/tree", "definitions": {"tree": {"type": "object", "properties": {"kids": {"items": {"$ref": "#/definitions/tree"}}}}}}`,
+			`{"properties":{"kids":{"items":{"$ref":"#"}}},"type":"object"}`,
+		},
+	} {
+		s, err := Parse(strings.NewReader(tt.schema), false, WithDraft(tt.draft))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := s.Dereference()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, b)
+		}
+		if _, err := Parse(bytes.NewReader(b), false, WithDraft(tt.draft)); err != nil {
+			t.Errorf("%s: %s", b, err)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	schema := `{"id":"http://example.com/root.json","properties":{"a":{"$ref":"#/definitions/a"},"b":{"items":[{"type":"string"},true]}},"definitions":{"a":{"maximum":1.50}},"x-unknown":{"type":"integer","<":[1,2]}}`
+	s, err := Parse(strings.NewReader(schema), false, WithDraft(Draft6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected, actual interface{}
+	json.Unmarshal([]byte(schema), &expected)
+	json.Unmarshal(b, &actual)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %s, got %s", schema, b)
+	}
+	if !bytes.Contains(b, []byte(`"maximum":1.50`)) {
+		t.Errorf("Expected numbers to be kept as they were, got %s", b)
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	schema := `{
+		"title": "Order",
+		"properties": {
+			"customer": {"properties": {"name": {"type": "string"}, "age": {"type": ["integer", "null"]}}, "required": ["name"]},
+			"status": {"enum": ["open", "closed"], "default": "open"},
+			"lines": {"items": {"format": "uri"}}
+		},
+		"x-unknown": {"type": "integer"}
+	}`
+	s, err := Parse(strings.NewReader(schema), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keywords := s.Keywords(); !reflect.DeepEqual(keywords, []string{"properties", "title", "x-unknown"}) {
+		t.Errorf("Unexpected keywords %v", keywords)
+	}
+	if s.Title() != "Order" {
+		t.Errorf("Unexpected title %q", s.Title())
+	}
+
+	schemas := make(map[string]*Schema)
+	var pointers []string
+	err = s.Walk(func(pointer string, s *Schema) error {
+		schemas[pointer] = s
+		pointers = append(pointers, pointer)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"", "/properties/customer", "/properties/customer/properties/age", "/properties/customer/properties/name",
+		"/properties/lines", "/properties/lines/items", "/properties/status"}
+	if !reflect.DeepEqual(pointers, expected) {
+		t.Errorf("Expected to walk %v, got %v", expected, pointers)
+	}
+	customer := schemas["/properties/customer"]
+	if required := customer.Required(); !reflect.DeepEqual(required, []string{"name"}) {
+		t.Errorf("Unexpected required properties %v", required)
+	}
+	if typ := customer.Properties()["age"].Type(); !reflect.DeepEqual(typ, []string{"integer", "null"}) {
+		t.Errorf("Unexpected type %v", typ)
+	}
+	status := schemas["/properties/status"]
+	if enum := status.Enum(); !reflect.DeepEqual(enum, []interface{}{"open", "closed"}) {
+		t.Errorf("Unexpected enum %v", enum)
+	}
+	if v, ok := status.Default(); !ok || v != "open" {
+		t.Errorf("Unexpected default %v", v)
+	}
+	if items, _ := schemas["/properties/lines"].Items(); items == nil || items.Format() != "uri" {
+		t.Error("Expected the items schema to have a format.")
+	}
+
+	// In draft 3 a property is required by its schema, which can be referred to.
+	s, err = Parse(strings.NewReader(`{"properties": {"name": {"$ref": "#/definitions/name"}}, "definitions": {"name": {"required": true}}}`), false, WithDraft(Draft3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if required := s.Required(); !reflect.DeepEqual(required, []string{"name"}) {
+		t.Errorf("Unexpected required properties %v", required)
+	}
+}
+
+func TestDraft3(t *testing.T) {
+	testSuites(t, Draft3, "draft3")
+}
+
 func TestDraft4(t *testing.T) {
+	testSuites(t, Draft4, "draft4")
+}
+
+func TestDraft6(t *testing.T) {
+	testSuites(t, Draft6, "draft6")
+}
+
+func TestDraft7(t *testing.T) {
+	testSuites(t, Draft7, "draft7")
+}
+
+func TestDraft2019(t *testing.T) {
+	testSuites(t, Draft2019, "draft2019-09")
+}
+
+func TestDraft2020(t *testing.T) {
+	testSuites(t, Draft2020, "draft2020-12")
+}
+
+func testSuites(t *testing.T, draft Draft, dir string) {
 	suites := []string{
-		filepath.Join("JSON-Schema-Test-Suite", "tests", "draft4"),
-		filepath.Join("tests", "draft4"),
+		filepath.Join("JSON-Schema-Test-Suite", "tests", dir),
+		filepath.Join("tests", dir),
 	}
 	var failures, successes int
 	schemaCache := make(map[string]*Schema)
@@ -43,7 +847,7 @@ func TestDraft4(t *testing.T) {
 		if _, err := os.Stat(testResources); err != nil {
 			t.Error("Test suite missing. Run `git submodule update --init` to download it.")
 		}
-		err := filepath.Walk(testResources, testFileRunner(t, &failures, &successes, &schemaCache))
+		err := filepath.Walk(testResources, testFileRunner(t, draft, &failures, &successes, &schemaCache))
 		if err != nil {
 			t.Error(err.Error())
 		}
@@ -51,7 +855,7 @@ func TestDraft4(t *testing.T) {
 	t.Logf("%d failed, %d succeeded.", failures, successes)
 }
 
-func testFileRunner(t *testing.T, failures, successes *int, schemaCache *map[string]*Schema) func(string, os.FileInfo, error) error {
+func testFileRunner(t *testing.T, draft Draft, failures, successes *int, schemaCache *map[string]*Schema) func(string, os.FileInfo, error) error {
 	return func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -74,7 +878,8 @@ func testFileRunner(t *testing.T, failures, successes *int, schemaCache *map[str
 		}
 
 		for _, cse := range testFile {
-			schema, parseErr := ParseWithCache(bytes.NewReader(cse.Schema), true, schemaCache)
+			schema := &Schema{Cache: *schemaCache}
+			parseErr := schema.Parse(bytes.NewReader(cse.Schema), true, WithDraft(draft))
 			for _, tst := range cse.Tests {
 				if parseErr != nil {
 					t.Error(parseErrMessage(parseErr, path, cse, tst))
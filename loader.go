@@ -0,0 +1,185 @@
+package jsonschema
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// A Loader loads the documents that external references refer to. The URI it is
+// given has no fragment. If the returned reader is an io.Closer, it is closed
+// once the document is parsed.
+type Loader interface {
+	Load(uri string) (io.Reader, error)
+}
+
+// LoaderFunc is an adapter to use an ordinary function as a Loader.
+type LoaderFunc func(uri string) (io.Reader, error)
+
+func (f LoaderFunc) Load(uri string) (io.Reader, error) {
+	return f(uri)
+}
+
+// The loader used when none is given with WithLoader, which fetches documents
+// with the default HTTP client.
+var defaultLoader Loader = HTTPLoader{}
+
+// SchemeLoader dispatches URIs to a loader by their scheme, like "file" or "https".
+type SchemeLoader map[string]Loader
+
+func (l SchemeLoader) Load(uri string) (io.Reader, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	loader, ok := l[u.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("no loader for scheme %q", u.Scheme)
+	}
+	return loader.Load(uri)
+}
+
+// FileLoader loads file:// URIs from the local file system.
+type FileLoader struct{}
+
+func (FileLoader) Load(uri string) (io.Reader, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "file" {
+		return nil, fmt.Errorf("%s is not a file URI", uri)
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("%s is not a local file", uri)
+	}
+	return os.Open(filepath.FromSlash(u.Path))
+}
+
+// FSLoader loads the URIs starting with BaseURI from a file system, such as an
+// embed.FS. The rest of the URI is the path of the file in FS.
+type FSLoader struct {
+	FS      fs.FS
+	BaseURI string
+}
+
+func (l FSLoader) Load(uri string) (io.Reader, error) {
+	if !strings.HasPrefix(uri, l.BaseURI) {
+		return nil, fmt.Errorf("%s is not under %s", uri, l.BaseURI)
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(uri, l.BaseURI), "/")
+	return l.FS.Open(name)
+}
+
+// MapLoader loads documents from memory by their URI.
+type MapLoader map[string][]byte
+
+func (l MapLoader) Load(uri string) (io.Reader, error) {
+	b, ok := l[uri]
+	if !ok {
+		return nil, fmt.Errorf("no document for %s", uri)
+	}
+	return bytes.NewReader(b), nil
+}
+
+// HTTPLoader loads http:// and https:// URIs.
+type HTTPLoader struct {
+	// The client the documents are fetched with. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// If positive, the maximum time a document takes to fetch.
+	Timeout time.Duration
+	// If positive, the maximum size of a document in bytes.
+	MaxBodySize int64
+	// If not empty, the hosts that documents can be fetched from. Other hosts
+	// are refused.
+	AllowedHosts []string
+}
+
+func (l HTTPLoader) Load(uri string) (io.Reader, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%s is not an HTTP URI", uri)
+	}
+	if !l.allowed(u.Hostname()) {
+		return nil, fmt.Errorf("host %s is not allowed", u.Hostname())
+	}
+	ctx := context.Background()
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if len(l.AllowedHosts) > 0 {
+		// The hosts that the requests are redirected to must be allowed too.
+		c := *client
+		checkRedirect := client.CheckRedirect
+		c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if !l.allowed(req.URL.Hostname()) {
+				return fmt.Errorf("redirect to host %s is not allowed", req.URL.Hostname())
+			}
+			if checkRedirect != nil {
+				return checkRedirect(req, via)
+			}
+			// The default policy of http.Client.
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		}
+		client = &c
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", uri, resp.Status)
+	}
+	// The body is read before returning, since the timeout applies to it too.
+	body := io.Reader(resp.Body)
+	if l.MaxBodySize > 0 {
+		body = io.LimitReader(resp.Body, l.MaxBodySize+1)
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if l.MaxBodySize > 0 && int64(len(b)) > l.MaxBodySize {
+		return nil, errors.New("document is larger than the maximum body size")
+	}
+	return bytes.NewReader(b), nil
+}
+
+func (l HTTPLoader) allowed(host string) bool {
+	if len(l.AllowedHosts) == 0 {
+		return true
+	}
+	for _, h := range l.AllowedHosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package jsonschema
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// The meta-schemas of the drafts are bundled with the package, so that schemas
+// can be checked against them without network access.
+//
+//go:embed metaschemas
+var metaSchemaFiles embed.FS
+
+// The files of the meta-schema of each draft. Since draft 2019-09 the
+// meta-schema is split into vocabulary meta-schemas, which are listed after it.
+var metaSchemaPaths = map[Draft][]string{
+	Draft3: {"draft-03.json"},
+	Draft4: {"draft-04.json"},
+	Draft6: {"draft-06.json"},
+	Draft7: {"draft-07.json"},
+	Draft2019: {
+		"draft2019-09/schema.json",
+		"draft2019-09/meta/core.json",
+		"draft2019-09/meta/applicator.json",
+		"draft2019-09/meta/validation.json",
+		"draft2019-09/meta/meta-data.json",
+		"draft2019-09/meta/format.json",
+		"draft2019-09/meta/content.json",
+	},
+	Draft2020: {
+		"draft2020-12/schema.json",
+		"draft2020-12/meta/core.json",
+		"draft2020-12/meta/applicator.json",
+		"draft2020-12/meta/unevaluated.json",
+		"draft2020-12/meta/validation.json",
+		"draft2020-12/meta/meta-data.json",
+		"draft2020-12/meta/format-annotation.json",
+		"draft2020-12/meta/content.json",
+	},
+}
+
+var metaSchemas struct {
+	sync.Once
+	byDraft map[Draft]*Schema
+	err     error
+}
+
+func loadMetaSchemas() {
+	metaSchemas.byDraft = make(map[Draft]*Schema, len(metaSchemaPaths))
+	for d, paths := range metaSchemaPaths {
+		cache := make(map[string]*Schema)
+		// The vocabulary meta-schemas are parsed first, so that the references
+		// of the meta-schema to them are found in the cache.
+		for i := len(paths) - 1; i >= 0; i-- {
+			f, err := metaSchemaFiles.Open(path.Join("metaschemas", paths[i]))
+			if err != nil {
+				metaSchemas.err = err
+				return
+			}
+			s, err := ParseWithCache(f, false, &cache, WithDraft(d))
+			f.Close()
+			if err != nil {
+				metaSchemas.err = fmt.Errorf("meta-schema %s: %s", paths[i], err)
+				return
+			}
+			if i == 0 {
+				metaSchemas.byDraft[d] = s
+			}
+		}
+	}
+}
+
+// metaSchema returns the bundled meta-schema of a draft.
+func metaSchema(d Draft) (*Schema, error) {
+	metaSchemas.Do(loadMetaSchemas)
+	if metaSchemas.err != nil {
+		return nil, metaSchemas.err
+	}
+	s, ok := metaSchemas.byDraft[d]
+	if !ok {
+		return nil, fmt.Errorf("no meta-schema for draft %d", d)
+	}
+	return s, nil
+}
+
+// bundledSchema returns the bundled meta-schema with a cache key, so that
+// references to meta-schemas don't have to be fetched.
+func bundledSchema(cacheKey string) (*Schema, bool) {
+	d, ok := draftURIs[cacheKey]
+	if !ok {
+		return nil, false
+	}
+	s, err := metaSchema(d)
+	return s, err == nil
+}
+
+// A MetaSchemaError is returned by the parse functions when a schema isn't valid
+// against the meta-schema of its draft, if the schema is parsed with the
+// ValidateAgainstMetaSchema option. The keypaths of its errors point into the
+// schema document.
+type MetaSchemaError struct {
+	Draft  Draft
+	Errors []ValidationError
+}
+
+func (e *MetaSchemaError) Error() string {
+	descriptions := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		descriptions[i] = fmt.Sprintf("%s: %s", err.JSONPointer(), err.Description)
+	}
+	return "schema isn't valid against its meta-schema: " + strings.Join(descriptions, "; ")
+}
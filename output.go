@@ -0,0 +1,212 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// An OutputFormat is one of the standardized output formats of draft 2019-09.
+type OutputFormat int
+
+const (
+	// OutputFlag only tells whether the instance is valid.
+	OutputFlag OutputFormat = iota
+	// OutputBasic lists the errors.
+	OutputBasic
+	// OutputDetailed nests the errors in a hierarchy mirroring the schema, where
+	// the units with a single child are replaced by it.
+	OutputDetailed
+	// OutputVerbose nests the errors in the full hierarchy of the schema
+	// locations leading to them.
+	OutputVerbose
+)
+
+// An OutputUnit is the result of the evaluation of a schema location in a
+// standardized output format. Its JSON encoding has the properties of the
+// standard, like "keywordLocation" and "instanceLocation".
+//
+// Since annotations aren't collected, the units of the detailed and verbose
+// formats are the schema locations leading to errors, and a valid instance
+// only has a root unit.
+type OutputUnit struct {
+	Valid bool
+	// A JSON pointer to the location in the schema, through the references
+	// that were followed.
+	KeywordLocation string
+	// The URI of the location in its document. It is empty if the document has
+	// no URI.
+	AbsoluteKeywordLocation string
+	// A JSON pointer to the location in the instance.
+	InstanceLocation string
+	// The description of the error, for the units of the keywords that raised
+	// errors.
+	Error string
+	// The units of the locations within this one.
+	Errors []OutputUnit
+
+	// Set for the root unit of the flag and basic formats, which has no
+	// location.
+	bare bool
+}
+
+func (u OutputUnit) MarshalJSON() ([]byte, error) {
+	if u.bare {
+		return json.Marshal(struct {
+			Valid  bool         `json:"valid"`
+			Errors []OutputUnit `json:"errors,omitempty"`
+		}{u.Valid, u.Errors})
+	}
+	return json.Marshal(struct {
+		Valid                   bool         `json:"valid"`
+		KeywordLocation         string       `json:"keywordLocation"`
+		AbsoluteKeywordLocation string       `json:"absoluteKeywordLocation,omitempty"`
+		InstanceLocation        string       `json:"instanceLocation"`
+		Error                   string       `json:"error,omitempty"`
+		Errors                  []OutputUnit `json:"errors,omitempty"`
+	}{u.Valid, u.KeywordLocation, u.AbsoluteKeywordLocation, u.InstanceLocation, u.Error, u.Errors})
+}
+
+// Output validates v and returns the result in an output format.
+func (s *Schema) Output(v interface{}, format OutputFormat) OutputUnit {
+	return NewOutput(s.Validate(nil, v), format)
+}
+
+// NewOutput returns the errors that Validate returned in an output format.
+func NewOutput(errs []ValidationError, format OutputFormat) OutputUnit {
+	valid := len(errs) == 0
+	switch format {
+	case OutputFlag:
+		return OutputUnit{Valid: valid, bare: true}
+	case OutputBasic:
+		out := OutputUnit{Valid: valid, bare: true}
+		var add func(errs []ValidationError)
+		add = func(errs []ValidationError) {
+			for _, e := range errs {
+				out.Errors = append(out.Errors, e.outputUnit())
+				for _, branch := range e.BranchErrors {
+					add(branch)
+				}
+			}
+		}
+		add(errs)
+		sortUnits(out.Errors)
+		return out
+	}
+
+	root := &outputNode{unit: OutputUnit{Valid: valid}}
+	if len(errs) > 0 {
+		root.unit = errs[0].outputUnitAt("")
+	}
+	for _, e := range errs {
+		if e.SchemaPath == "" {
+			// A false schema.
+			root.unit.Error = e.Description
+			continue
+		}
+		root.add(e, 0)
+	}
+	return root.build(format == OutputDetailed)
+}
+
+// A node of the hierarchy of the detailed and verbose formats.
+type outputNode struct {
+	unit     OutputUnit
+	children []*outputNode
+	// The children that aren't errors by location.
+	byLocation map[string]*outputNode
+}
+
+// child returns the child of n at the location of unit, which is added if it
+// doesn't exist.
+func (n *outputNode) child(unit OutputUnit) *outputNode {
+	key := unit.KeywordLocation + " " + unit.InstanceLocation
+	if c, ok := n.byLocation[key]; ok {
+		return c
+	}
+	if n.byLocation == nil {
+		n.byLocation = make(map[string]*outputNode)
+	}
+	c := &outputNode{unit: unit}
+	n.byLocation[key] = c
+	n.children = append(n.children, c)
+	return c
+}
+
+// add adds the units of the schema path of e below n, whose path has depth
+// tokens, along with the units of its branch errors.
+func (n *outputNode) add(e ValidationError, depth int) {
+	tokens := strings.Split(e.SchemaPath, "/")[1:]
+	node := n
+	for i := depth + 1; i < len(tokens); i++ {
+		node = node.child(e.outputUnitAt("/" + strings.Join(tokens[:i], "/")))
+	}
+	leaf := &outputNode{unit: e.outputUnit()}
+	node.children = append(node.children, leaf)
+	for _, branch := range e.BranchErrors {
+		for _, b := range branch {
+			leaf.add(b, len(tokens))
+		}
+	}
+}
+
+// build returns the unit of n with its children. If condense is set, the
+// children with a single child are replaced by it.
+func (n *outputNode) build(condense bool) OutputUnit {
+	u := n.unit
+	for _, c := range n.children {
+		for condense && len(c.children) == 1 {
+			c = c.children[0]
+		}
+		u.Errors = append(u.Errors, c.build(condense))
+	}
+	sortUnits(u.Errors)
+	return u
+}
+
+// sortUnits sorts units by location, since the order of the errors of
+// Validate isn't stable.
+func sortUnits(units []OutputUnit) {
+	sort.SliceStable(units, func(i, j int) bool {
+		if units[i].KeywordLocation != units[j].KeywordLocation {
+			return units[i].KeywordLocation < units[j].KeywordLocation
+		}
+		return units[i].InstanceLocation < units[j].InstanceLocation
+	})
+}
+
+// outputUnit returns the unit of the keyword that raised e.
+func (e ValidationError) outputUnit() OutputUnit {
+	return OutputUnit{
+		KeywordLocation:         e.SchemaPath,
+		AbsoluteKeywordLocation: absoluteOutputLocation(e.AbsoluteKeywordLocation),
+		InstanceLocation:        e.JSONPointer(),
+		Error:                   e.Description,
+	}
+}
+
+// outputUnitAt returns the unit of a location on the schema path of e. Its
+// instance location is the one of the innermost schema containing it.
+func (e ValidationError) outputUnitAt(path string) OutputUnit {
+	for _, f := range e.trail {
+		if path == f.path || strings.HasPrefix(path, f.path+"/") {
+			rest := url.URL{Fragment: strings.TrimPrefix(path, f.path)}
+			return OutputUnit{
+				KeywordLocation:         path,
+				AbsoluteKeywordLocation: absoluteOutputLocation(f.absoluteLocation + rest.EscapedFragment()),
+				InstanceLocation:        f.instanceLocation,
+			}
+		}
+	}
+	return OutputUnit{KeywordLocation: path}
+}
+
+// absoluteOutputLocation leaves out the locations in documents without a URI,
+// which are only fragments.
+func absoluteOutputLocation(location string) string {
+	if strings.HasPrefix(location, "#") {
+		return ""
+	}
+	return location
+}
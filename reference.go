@@ -4,8 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
+	"io"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -24,11 +25,16 @@ func (e *EmbeddedSchemas) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// UnmarshalArray, UnmarshalObject and UnmarshalSingle are used by validators
+// that only accept embedded schemas of one shape.
 func (e *EmbeddedSchemas) UnmarshalArray(b []byte) error {
 	var schemas []*Schema
 	if err := json.Unmarshal(b, &schemas); err != nil {
 		return err
 	}
+	if *e == nil {
+		*e = make(EmbeddedSchemas, len(schemas))
+	}
 	for i, v := range schemas {
 		(*e)[strconv.Itoa(i)] = v
 	}
@@ -40,6 +46,9 @@ func (e *EmbeddedSchemas) UnmarshalObject(b []byte) error {
 	if err := json.Unmarshal(b, &m); err != nil {
 		return err
 	}
+	if *e == nil {
+		*e = make(EmbeddedSchemas, len(m))
+	}
 	for k, v := range m {
 		(*e)[k] = v
 	}
@@ -51,21 +60,62 @@ func (e *EmbeddedSchemas) UnmarshalSingle(b []byte) error {
 	if err := json.Unmarshal(b, &s); err != nil {
 		return err
 	}
+	if *e == nil {
+		*e = make(EmbeddedSchemas, 1)
+	}
 	(*e)[""] = &s
 	return nil
 }
 
 // ResolveRefs starts a depth-first search through a document for schemas containing
-// the 'ref' validator. It completely resolves each one found.
-func (s *Schema) ResolveRefs(loadExternal bool) {
-	s.resolveSelfAndBelow(*s, *s, loadExternal)
+// the 'ref' validator. It completely resolves each one found, and returns the
+// references that couldn't be resolved.
+func (s *Schema) ResolveRefs(loadExternal bool) UnresolvedRefs {
+	return s.resolveRefs(&resolver{loadExternal: loadExternal, loader: defaultLoader})
+}
+
+// A resolver holds the state of the resolution of the references of a document.
+type resolver struct {
+	loadExternal bool
+	loader       Loader
+	baseURI      string
+	// The options that external documents are parsed with.
+	opts []ParseOption
+	// The schemas of the document and of the documents it refers to, by the
+	// URIs they are identified with.
+	cache      map[string]*Schema
+	unresolved UnresolvedRefs
+}
+
+func (s *Schema) resolveRefs(res *resolver) UnresolvedRefs {
+	if s.Cache == nil {
+		s.Cache = make(map[string]*Schema)
+	}
+	res.cache = s.Cache
+	parent := s
+	if res.baseURI != "" {
+		parent = &Schema{id: res.baseURI}
+	}
+	// All the ids of the document are known before references are resolved,
+	// since references can point to schemas that follow them.
+	s.identify(parent, res)
+	s.resolveSelfAndBelow(s, res)
+	s.checkRefCycles(res)
+	return res.unresolved
 }
 
-func (s *Schema) resolveSelfAndBelow(parentSchema, rootSchema Schema, loadExternal bool) {
-	if parentSchema.id != "" && parentSchema.id != s.id {
-		s.parentId = parentSchema.id
+// identify resolves the ids of s and its subschemas against the ids of their
+// parents, and caches the schemas by them.
+func (s *Schema) identify(parentSchema *Schema, res *resolver) {
+	parentId := parentSchema.id
+	if parentId == "" || strings.HasPrefix(parentId, "#") {
+		// A schema without an id of its own keeps the resolution scope of its parent.
+		parentId = parentSchema.parentId
+	}
+	if parentId != "" && parentId != s.id {
+		s.parentId = parentId
 		sURL, sURLErr := url.Parse(s.id)
-		pURL, pURLErr := url.Parse(parentSchema.id)
+		pURL, pURLErr := url.Parse(parentId)
 		if sURLErr == nil && pURLErr == nil && pURL.IsAbs() && !sURL.IsAbs() {
 			if strings.HasPrefix(s.id, "#") {
 				pURL.Fragment = strings.TrimPrefix(s.id, "#")
@@ -76,124 +126,405 @@ func (s *Schema) resolveSelfAndBelow(parentSchema, rootSchema Schema, loadExtern
 	}
 	cacheKey, err := resolveCacheKey(s.id)
 	if err == nil {
-		if _, ok := rootSchema.Cache[cacheKey]; !ok {
-			rootSchema.Cache[cacheKey] = s
+		if _, ok := res.cache[cacheKey]; !ok {
+			res.cache[cacheKey] = s
+		}
+	}
+	for _, n := range s.nodes {
+		for _, sch := range n.EmbeddedSchemas {
+			sch.identify(s, res)
+		}
+	}
+}
+
+// rootSchema is the schema resource containing s, which fragments like "#" and
+// "#/definitions/foo" are resolved against.
+func (s *Schema) resolveSelfAndBelow(rootSchema *Schema, res *resolver) {
+	if s.isResource {
+		rootSchema = s
+	}
+	s.resolveSelf(rootSchema, res)
+	s.resolveBelow(rootSchema, res)
+}
+
+// resolveSelf links the references of s to the schemas they refer to, which are
+// followed when s is evaluated.
+func (s *Schema) resolveSelf(rootSchema *Schema, res *resolver) {
+	for _, n := range s.nodes {
+		if l, ok := n.Validator.(refLinker); ok {
+			r := l.reference()
+			if sch, ok := s.resolveRef(r.uri, rootSchema, res); ok {
+				r.target = sch
+			}
 		}
 	}
-	s.resolveSelf(rootSchema, loadExternal)
-	s.resolveBelow(rootSchema, loadExternal)
 }
 
-func (s *Schema) resolveSelf(rootSchema Schema, loadExternal bool) {
-	if str, ok := s.hasRef(); ok {
-		sch, err := s.refToSchema(str, rootSchema, loadExternal)
-		if err != nil {
+func circularRef(chain []string, pointer string) UnresolvedRef {
+	return UnresolvedRef{
+		Ref:     chain[0],
+		Pointer: pointer,
+		Reason:  CircularRef,
+		Err:     fmt.Errorf("circular $ref chain %s", strings.Join(chain, " -> ")),
+	}
+}
+
+// checkRefCycles finds the cycles of references that would never end when the
+// schemas are evaluated: references that lead back to themselves through other
+// references and through applicators like allOf or not, which apply their
+// subschemas to the same instance location. A schema that refers to itself
+// through a subschema that applies to a part of the instance, like a tree whose
+// items refer to the root, is not a cycle. The references of a cycle are
+// unlinked.
+func (s *Schema) checkRefCycles(res *resolver) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[*Schema]int)
+	// The schemas being visited, with the references they were reached by.
+	type step struct {
+		schema *Schema
+		via    *ref
+	}
+	var stack []step
+	var visit func(sch *Schema, via *ref)
+	visit = func(sch *Schema, via *ref) {
+		switch state[sch] {
+		case visited:
+			return
+		case visiting:
+			start := len(stack) - 1
+			for stack[start].schema != sch {
+				start--
+			}
+			var refs []*ref
+			var pointer string
+			for i := start + 1; i <= len(stack); i++ {
+				r, from := via, stack[len(stack)-1].schema
+				if i < len(stack) {
+					r, from = stack[i].via, stack[i-1].schema
+				}
+				if r != nil {
+					if len(refs) == 0 {
+						pointer = from.pointer
+					}
+					refs = append(refs, r)
+				}
+			}
+			chain := make([]string, len(refs))
+			for i, r := range refs {
+				chain[i] = r.uri
+				r.target = nil
+			}
+			res.unresolved = append(res.unresolved, circularRef(chain, pointer))
 			return
 		}
-		*s = *sch
-		s.resolveSelf(rootSchema, loadExternal)
+		state[sch] = visiting
+		stack = append(stack, step{sch, via})
+		for _, key := range sch.inPlaceKeywords() {
+			n := sch.nodes[key]
+			if l, ok := n.Validator.(refLinker); ok {
+				if r := l.reference(); r.target != nil {
+					visit(r.target, r)
+				}
+				continue
+			}
+			for _, name := range sortedKeys(n.EmbeddedSchemas) {
+				visit(n.EmbeddedSchemas[name], nil)
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[sch] = visited
+	}
+	walkResources(s, func(sch, resource *Schema) {
+		visit(sch, nil)
+	})
+}
+
+// The keywords that apply their subschemas to the same instance location as
+// the schema containing them, besides references.
+var inPlaceApplicators = map[string]bool{
+	"allOf": true, "anyOf": true, "oneOf": true, "not": true,
+	"if": true, "then": true, "else": true,
+	"dependencies": true, "dependentSchemas": true,
+	// Draft 3.
+	"extends": true, "type": true, "disallow": true,
+}
+
+// inPlaceKeywords returns the references and in-place applicators of s in
+// alphabetical order. Up to draft 7 only "$ref" applies next to "$ref".
+func (s *Schema) inPlaceKeywords() []string {
+	if _, ok := s.nodes["$ref"]; ok && s.draft < Draft2019 {
+		return []string{"$ref"}
+	}
+	var keys []string
+	for key, n := range s.nodes {
+		if _, ok := n.Validator.(refLinker); ok || inPlaceApplicators[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(e EmbeddedSchemas) []string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// resolveRef resolves a reference of s, and records it as unresolved if it
+// can't be resolved.
+func (s *Schema) resolveRef(uri string, rootSchema *Schema, res *resolver) (*Schema, bool) {
+	sch, err := s.refToSchema(uri, rootSchema, res)
+	if err != nil {
+		unresolved := UnresolvedRef{Ref: uri, Pointer: s.pointer, Reason: PointerNotFound, Err: err}
+		if e, ok := err.(*refError); ok {
+			unresolved.Reason, unresolved.Err = e.reason, e.err
+		}
+		res.unresolved = append(res.unresolved, unresolved)
+		return nil, false
+	}
+	return sch, true
+}
+
+// A refError is an error of refToSchema that isn't caused by the fragment of
+// the reference.
+type refError struct {
+	reason RefErrorReason
+	err    error
+}
+
+func (e *refError) Error() string {
+	return e.err.Error()
+}
+
+// RefErrorReason is the reason a reference couldn't be resolved.
+type RefErrorReason int
+
+const (
+	// The reference is to an external document, and external schemas are disabled.
+	ExternalDisabled RefErrorReason = iota
+	// The external document couldn't be fetched or parsed.
+	FetchFailed
+	// The fragment of the reference doesn't identify a schema in the document.
+	PointerNotFound
+	// The reference is part of a cycle of references, which never ends.
+	CircularRef
+	// The reference is relative to a document, like "other.json", but there is
+	// no id or base URI to resolve it against. See WithBaseURI.
+	NoBaseURI
+)
+
+func (r RefErrorReason) String() string {
+	switch r {
+	case ExternalDisabled:
+		return "external schemas are disabled"
+	case FetchFailed:
+		return "fetch failed"
+	case PointerNotFound:
+		return "pointer not found"
+	case CircularRef:
+		return "circular reference"
+	case NoBaseURI:
+		return "no base URI"
 	}
+	return fmt.Sprintf("RefErrorReason(%d)", int(r))
+}
+
+// An UnresolvedRef is a reference that couldn't be resolved.
+type UnresolvedRef struct {
+	// The value of the reference keyword.
+	Ref string
+	// A JSON pointer to the schema containing the reference in its document.
+	Pointer string
+	Reason  RefErrorReason
+	Err     error
+}
+
+func (e UnresolvedRef) Error() string {
+	return fmt.Sprintf("failed to resolve $ref %s at %q (%s): %s", e.Ref, e.Pointer, e.Reason, e.Err)
+}
+
+// UnresolvedRefs is returned by the parse functions if references of a schema
+// can't be resolved, unless the schema is parsed with LenientRefs.
+type UnresolvedRefs []UnresolvedRef
+
+func (e UnresolvedRefs) Error() string {
+	descriptions := make([]string, len(e))
+	for i, err := range e {
+		descriptions[i] = err.Error()
+	}
+	return "unresolved references: " + strings.Join(descriptions, "; ")
+}
+
+// A refLinker is a validator (such as "$ref" or "$dynamicRef") that is linked
+// to the schema it refers to.
+type refLinker interface {
+	reference() *ref
+}
+
+func (r *ref) reference() *ref {
+	return r
 }
 
-// TODO: test that we fail gracefully if the schema contains infinitely looping "$ref"s.
-func (s *Schema) resolveBelow(rootSchema Schema, loadExternal bool) {
+func (s *Schema) resolveBelow(rootSchema *Schema, res *resolver) {
 	if s.resolved == true {
 		return
 	}
 	s.resolved = true
 	for _, n := range s.nodes {
 		for _, sch := range n.EmbeddedSchemas {
-			sch.resolveSelfAndBelow(*s, rootSchema, loadExternal)
+			sch.resolveSelfAndBelow(rootSchema, res)
 		}
 	}
 }
 
-func (s *Schema) hasRef() (string, bool) {
+func (s *Schema) hasRef() (*ref, bool) {
 	for _, n := range s.nodes {
 		if r, ok := n.Validator.(*ref); ok {
-			return string(*r), true
+			return r, true
 		}
 	}
-	return "", false
+	return nil, false
 }
 
 // TODO: This is hacky. Look into using a library like gojsonpointer[1] instead.
 //
 // [1] https://github.com/xeipuuv/gojsonpointer
-func (s *Schema) refToSchema(str string, rootSchema Schema, loadExternal bool) (*Schema, error) {
-	parentURL, err := url.Parse(s.parentId)
+func (s *Schema) refToSchema(str string, rootSchema *Schema, res *resolver) (*Schema, error) {
+	baseId := s.parentId
+	if s.draft >= Draft2019 && s.isResource && s.id != "" {
+		// Since draft 2019-09 "$ref" is resolved against a neighboring "$id".
+		baseId = s.id
+	}
+	parentURL, err := url.Parse(baseId)
 	if err == nil && parentURL.IsAbs() {
 		sURL, err := url.Parse(str)
 		if err == nil && !sURL.IsAbs() && !strings.HasPrefix(str, "#") {
 			str = parentURL.ResolveReference(sURL).String()
 		}
 	}
+	if u, err := url.Parse(str); err == nil && !u.IsAbs() && (u.Host != "" || u.Path != "") {
+		return new(Schema), &refError{NoBaseURI, fmt.Errorf("relative reference %s has no base URI", str)}
+	}
 
 	var split []string
-	url, err := url.Parse(str)
+	strURL, err := url.Parse(str)
 	cacheKey, cacheKeyErr := resolveCacheKey(str)
 	if err == nil && cacheKeyErr == nil {
-		cachedSchema, ok := rootSchema.Cache[cacheKey]
+		cachedSchema, ok := res.cache[cacheKey]
+		if !ok {
+			cachedSchema, ok = bundledSchema(cacheKey)
+		}
 		if ok {
-			rootSchema = *cachedSchema
+			rootSchema = cachedSchema
 		} else {
 			// Handle external URIs.
-			if !loadExternal {
-				return new(Schema), errors.New("external schemas are disabled")
+			if !res.loadExternal {
+				return new(Schema), &refError{ExternalDisabled, errors.New("external schemas are disabled")}
 			}
-			resp, err := http.Get(str)
+			docURL := *strURL
+			docURL.Fragment, docURL.RawFragment = "", ""
+			r, err := res.loader.Load(docURL.String())
 			if err != nil {
-				return new(Schema), errors.New("bad external url")
+				return new(Schema), &refError{FetchFailed, fmt.Errorf("bad external url: %s", err)}
+			}
+			if c, ok := r.(io.Closer); ok {
+				defer c.Close()
 			}
-			defer resp.Body.Close()
-			s, err := ParseWithCache(resp.Body, loadExternal, &rootSchema.Cache)
+			// A document that doesn't declare a draft has the draft of the schema
+			// referring to it.
+			opts := append(append([]ParseOption{}, res.opts...), WithDraft(s.draft), WithBaseURI(docURL.String()))
+			doc, err := ParseWithCache(r, res.loadExternal, &res.cache, opts...)
 			if err != nil {
-				return new(Schema), errors.New("error parsing external doc")
+				return new(Schema), &refError{FetchFailed, fmt.Errorf("error parsing external doc: %s", err)}
 			}
-			rootSchema.Cache[cacheKey] = s
-			rootSchema = *s
+			res.cache[cacheKey] = doc
+			rootSchema = doc
 		}
-		str = url.Fragment
+		str = strURL.EscapedFragment()
 	}
 
 	// Remove the prefix from internal URIs.
 	str = strings.TrimPrefix(str, "#")
-	str = strings.TrimPrefix(str, "/")
-
-	split = strings.Split(str, "/")
+	// The fragment of a URI is percent-encoded.
+	str, err = url.PathUnescape(str)
+	if err != nil {
+		return new(Schema), err
+	}
+	if str != "" && !strings.HasPrefix(str, "/") {
+		return resolveAnchor(str, rootSchema)
+	}
+	if str == "" {
+		return rootSchema, nil
+	}
+	split = strings.Split(strings.TrimPrefix(str, "/"), "/")
 	// Make replacements.
 	for i, v := range split {
-		r := strings.NewReplacer("~0", "~", "~1", "/", "%25", "%")
+		r := strings.NewReplacer("~1", "/", "~0", "~")
 		split[i] = r.Replace(v)
 	}
 	// Resolve the local part of the URI.
 	return resolveLocalPath(split, rootSchema, str)
 }
 
-// TODO: add code and tests for references more than one level deep.
-func resolveLocalPath(split []string, rootSchema Schema, str string) (*Schema, error) {
-	switch len(split) {
-	case 1:
-		if split[0] == "" {
-			return &rootSchema, nil
+// resolveLocalPath follows the reference tokens of a JSON pointer from the root
+// schema. Each schema keyword is followed either by the name or index of one of
+// its embedded schemas (as in "/properties/foo" or "/allOf/2"), or directly by
+// the keywords of its only embedded schema (as in "/not/type").
+func resolveLocalPath(split []string, rootSchema *Schema, str string) (*Schema, error) {
+	s := rootSchema
+	for i := 0; i < len(split); i++ {
+		v, ok := s.nodes[split[i]]
+		if !ok {
+			return new(Schema), fmt.Errorf("failed to resolve %s", str)
 		}
-		v, ok := rootSchema.nodes[split[0]]
-		if ok == false {
-			break
-		}
-		if s, ok := v.EmbeddedSchemas[""]; ok {
-			return s, nil
+		if i+1 < len(split) {
+			if sch, ok := v.EmbeddedSchemas[split[i+1]]; ok {
+				s = sch
+				i++
+				continue
+			}
 		}
-	case 2:
-		v, ok := rootSchema.nodes[split[0]]
-		if ok == false {
-			break
+		sch, ok := v.EmbeddedSchemas[""]
+		if !ok {
+			return new(Schema), fmt.Errorf("failed to resolve %s", str)
 		}
-		if s, ok := v.EmbeddedSchemas[split[1]]; ok {
-			return s, nil
+		s = sch
+	}
+	return s, nil
+}
+
+// resolveAnchor finds the schema identified by a plain name fragment, which is
+// declared with "$anchor" since draft 2019-09 and with an id like "#foo" before.
+func resolveAnchor(name string, rootSchema *Schema) (*Schema, error) {
+	if s := findAnchor(name, rootSchema, make(map[*Schema]bool)); s != nil {
+		return s, nil
+	}
+	return new(Schema), fmt.Errorf("failed to resolve anchor %s", name)
+}
+
+func findAnchor(name string, s *Schema, visited map[*Schema]bool) *Schema {
+	if visited[s] {
+		return nil
+	}
+	visited[s] = true
+	if s.anchor == name || s.dynamicAnchor == name || s.draft < Draft2019 && s.id == "#"+name {
+		return s
+	}
+	for _, n := range s.nodes {
+		for _, sch := range n.EmbeddedSchemas {
+			if found := findAnchor(name, sch, visited); found != nil {
+				return found
+			}
 		}
 	}
-	return new(Schema), fmt.Errorf("failed to resolve %s", str)
+	return nil
 }
 
 func resolveCacheKey(id string) (string, error) {
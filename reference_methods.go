@@ -22,10 +22,42 @@ func (a *additionalItems) LinkEmbedded() map[string]*Schema {
 	return a.EmbeddedSchemas
 }
 
+func (a *contains) LinkEmbedded() map[string]*Schema {
+	return a.EmbeddedSchemas
+}
+
 func (a *dependencies) LinkEmbedded() map[string]*Schema {
 	return a.EmbeddedSchemas
 }
 
+func (a *dependentSchemas) LinkEmbedded() map[string]*Schema {
+	return a.EmbeddedSchemas
+}
+
+func (a *definitions) LinkEmbedded() map[string]*Schema {
+	return a.EmbeddedSchemas
+}
+
+func (a *draft3Type) LinkEmbedded() map[string]*Schema {
+	return a.EmbeddedSchemas
+}
+
+func (a *extends) LinkEmbedded() map[string]*Schema {
+	return a.EmbeddedSchemas
+}
+
+func (a *ifValidator) LinkEmbedded() map[string]*Schema {
+	return a.EmbeddedSchemas
+}
+
+func (a *thenValidator) LinkEmbedded() map[string]*Schema {
+	return a.EmbeddedSchemas
+}
+
+func (a *elseValidator) LinkEmbedded() map[string]*Schema {
+	return a.EmbeddedSchemas
+}
+
 func (a *items) LinkEmbedded() map[string]*Schema {
 	return a.EmbeddedSchemas
 }
@@ -38,6 +70,14 @@ func (a *patternProperties) LinkEmbedded() map[string]*Schema {
 	return a.EmbeddedSchemas
 }
 
+func (a *propertyNames) LinkEmbedded() map[string]*Schema {
+	return a.EmbeddedSchemas
+}
+
+func (a *prefixItems) LinkEmbedded() map[string]*Schema {
+	return a.EmbeddedSchemas
+}
+
 func (a *properties) LinkEmbedded() map[string]*Schema {
 	return a.EmbeddedSchemas
 }
@@ -49,3 +89,11 @@ func (a *oneOf) LinkEmbedded() map[string]*Schema {
 func (a *other) LinkEmbedded() map[string]*Schema {
 	return a.EmbeddedSchemas
 }
+
+func (a *unevaluatedItems) LinkEmbedded() map[string]*Schema {
+	return a.EmbeddedSchemas
+}
+
+func (a *unevaluatedProperties) LinkEmbedded() map[string]*Schema {
+	return a.EmbeddedSchemas
+}
@@ -3,7 +3,10 @@ package jsonschema
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -14,10 +17,11 @@ func normalizeNumber(v interface{}) (n interface{}, err error) {
 	switch t := v.(type) {
 
 	case json.Number:
-		if strings.Contains(t.String(), ".") {
+		if strings.ContainsAny(t.String(), ".eE") {
+			n, err = t.Float64()
+		} else if n, err = t.Int64(); err != nil {
+			// The number is too large for an int64.
 			n, err = t.Float64()
-		} else {
-			n, err = t.Int64()
 		}
 
 	case float32:
@@ -52,3 +56,56 @@ func normalizeNumber(v interface{}) (n interface{}, err error) {
 
 	return
 }
+
+// unmarshalNonNegativeInteger decodes the value of a keyword (such as maxLength)
+// that must be a non-negative integer. Since draft 6 a number with a zero
+// fractional part, like 2.0, is an integer.
+func unmarshalNonNegativeInteger(b []byte, keyword string) (int, error) {
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return 0, err
+	}
+	r, ok := new(big.Rat).SetString(n.String())
+	if !ok || !r.IsInt() || !r.Num().IsInt64() {
+		return 0, fmt.Errorf("%s must be an integer", keyword)
+	}
+	i := r.Num().Int64()
+	if i < 0 {
+		return 0, fmt.Errorf("%s cannot be smaller than zero", keyword)
+	}
+	return int(i), nil
+}
+
+// ratFromNumber converts v to an exact fraction if it is a supported number type.
+// It returns nil if v isn't a number.
+func ratFromNumber(v interface{}) (*big.Rat, error) {
+	if n, ok := v.(json.Number); ok {
+		if r, ok := new(big.Rat).SetString(n.String()); ok {
+			return r, nil
+		}
+		return nil, fmt.Errorf("%s is not a valid number", n)
+	}
+	normalized, err := normalizeNumber(v)
+	if err != nil {
+		return nil, err
+	}
+	switch n := normalized.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(n), nil
+	case float64:
+		if math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, fmt.Errorf("%v is not a valid number", n)
+		}
+		// Use the shortest decimal representation of the float, so that 0.0075
+		// stays a multiple of 0.0001.
+		r, _ := new(big.Rat).SetString(strconv.FormatFloat(n, 'g', -1, 64))
+		return r, nil
+	}
+	return nil, nil
+}
+
+// escapePointerToken escapes a reference token of a JSON pointer, as described
+// in RFC 6901.
+func escapePointerToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
@@ -7,15 +7,12 @@
 package jsonschema
 
 import (
-	"encoding/json"
 	"reflect"
-	"strings"
 )
 
 // NEW FOR JSONSCHEMA
 var stringType = reflect.TypeOf("")
 var boolType = reflect.TypeOf(false)
-var jsonNumberType = reflect.TypeOf(json.Number(""))
 
 // During deepValueEqual, must keep track of checks that are
 // in progress.  The comparison algorithm assumes that all
@@ -52,13 +49,16 @@ func deepValueEqual(v1, v2 reflect.Value, visited map[visit]bool, depth int) boo
 	// because reflect.Kind doesn't distinguish between json.Number and string.
 	//
 	// NOTE: We use the switch on v2 here instead of v1 like the switch below. Since this package controls
-	// the deserialization of schemas we know that if v2 is a number it will always be a json.Number.
-	// We can't say the same for v1.
+	// the deserialization of schemas we know what types v2 can have. We can't say the same for v1.
 	//
-	// TODO: We need to add tests where v2 is an integer and v1 is a float. Then we need code to cover
-	// that situation.
+	// Numbers are compared by value, so an integer is equal to a float with the same value
+	// (e.g. 1 and 1.0).
 	b1 := v1.Interface()
 	b2 := v2.Interface()
+	if c2, ok := normalizedNumber(b2); ok {
+		c1, ok := normalizedNumber(b1)
+		return ok && numbersEqual(c1, c2)
+	}
 	switch v2.Type() {
 	case stringType:
 		c1, ok1 := b1.(string)
@@ -74,36 +74,6 @@ func deepValueEqual(v1, v2 reflect.Value, visited map[visit]bool, depth int) boo
 			return false
 		}
 		return c1 == c2
-	case jsonNumberType:
-		norm, err := normalizeNumber(b1)
-		if err != nil {
-			return false
-		}
-		jnum, ok := b2.(json.Number)
-		if !ok {
-			return false
-		}
-		if strings.Contains(jnum.String(), ".") {
-			c2, err := jnum.Float64()
-			if err != nil {
-				return false
-			}
-			c1, ok := norm.(float64)
-			if !ok {
-				return false
-			}
-			return c1 == c2
-		} else {
-			c2, err := jnum.Int64()
-			if err != nil {
-				return false
-			}
-			c1, ok := norm.(int64)
-			if !ok {
-				return false
-			}
-			return c1 == c2
-		}
 	}
 	// END NEW FOR JSONSCHEMA
 
@@ -219,6 +189,42 @@ func deepValueEqual(v1, v2 reflect.Value, visited map[visit]bool, depth int) boo
 	}
 }
 
+// NEW FOR JSONSCHEMA
+//
+// normalizedNumber returns the normalized form of v (see normalizeNumber) and
+// whether v is a number at all.
+func normalizedNumber(v interface{}) (interface{}, bool) {
+	n, err := normalizeNumber(v)
+	if err != nil {
+		return nil, false
+	}
+	switch n.(type) {
+	case int64, float64:
+		return n, true
+	}
+	return nil, false
+}
+
+// NEW FOR JSONSCHEMA
+//
+// numbersEqual compares two normalized numbers by value.
+func numbersEqual(a, b interface{}) bool {
+	if i, ok := a.(int64); ok {
+		if j, ok := b.(int64); ok {
+			return i == j
+		}
+	}
+	return toFloat(a) == toFloat(b)
+}
+
+// NEW FOR JSONSCHEMA
+func toFloat(n interface{}) float64 {
+	if i, ok := n.(int64); ok {
+		return float64(i)
+	}
+	return n.(float64)
+}
+
 // DeepEqual tests for deep equality. It uses normal == equality where
 // possible but will scan elements of arrays, slices, maps, and fields of
 // structs. In maps, keys are compared with == but elements use deep
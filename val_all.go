@@ -1,8 +1,13 @@
 package jsonschema
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -10,15 +15,17 @@ type allOf struct {
 	EmbeddedSchemas
 }
 
-// TODO: though it isn't covered by tests, this is isn't right because if the JSON
-// used to create allOf.EmbeddedSchemas is an object or a single schema it will
-// still be unmarshaled into allOf.EmbeddedSchemas. allOf should only recognize an
-// array of schemas, not an object or a single schema.
-//
-// This (and similar validators) need custom UnmarshalJSON methods.
-func (a allOf) Validate(keypath []string, v interface{}) (valErrs []ValidationError) {
+func (a *allOf) UnmarshalJSON(b []byte) error {
+	return a.EmbeddedSchemas.UnmarshalArray(b)
+}
+
+func (a allOf) Validate(keypath []string, v interface{}) []ValidationError {
+	return a.Evaluate(nil, keypath, v).Errors
+}
+
+func (a allOf) Evaluate(scope Scope, keypath []string, v interface{}) (r Result) {
 	for _, s := range a.EmbeddedSchemas {
-		valErrs = append(valErrs, s.Validate(keypath, v)...)
+		r.merge(s.evaluate(scope, keypath, v))
 	}
 	return
 }
@@ -27,14 +34,85 @@ type anyOf struct {
 	EmbeddedSchemas
 }
 
+func (a *anyOf) UnmarshalJSON(b []byte) error {
+	return a.EmbeddedSchemas.UnmarshalArray(b)
+}
+
 func (a anyOf) Validate(keypath []string, v interface{}) []ValidationError {
-	for _, s := range a.EmbeddedSchemas {
-		if s.Validate(keypath, v) == nil {
-			return nil
+	return a.Evaluate(nil, keypath, v).Errors
+}
+
+// Evaluate doesn't stop at the first valid schema, because the properties and
+// items evaluated by every valid schema are reported.
+func (a anyOf) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	var r Result
+	var succeeded bool
+	branchErrors := make([][]ValidationError, len(a.EmbeddedSchemas))
+	for i := range branchErrors {
+		sr := a.EmbeddedSchemas[strconv.Itoa(i)].evaluate(scope, keypath, v)
+		if len(sr.Errors) == 0 {
+			r.merge(sr)
+			succeeded = true
 		}
+		branchErrors[i] = sr.Errors
+	}
+	if !succeeded {
+		return Result{Errors: []ValidationError{
+			{Keypath: keypath, Keyword: "anyOf", Code: CodeAnyOf, BranchErrors: branchErrors,
+				Description: "Validation failed for each schema in 'anyOf'."}}}
+	}
+	return r
+}
+
+type constValidator struct {
+	value interface{}
+}
+
+func (c *constValidator) UnmarshalJSON(b []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	return decoder.Decode(&c.value)
+}
+
+func (c constValidator) Validate(keypath []string, v interface{}) []ValidationError {
+	if DeepEqual(v, c.value) {
+		return nil
 	}
 	return []ValidationError{
-		{keypath, "Validation failed for each schema in 'anyOf'."}}
+		{Keypath: keypath, Keyword: "const", Code: CodeConst, Params: map[string]interface{}{"value": c.value},
+			Description: fmt.Sprintf("Const error. The data must be equal to %v.", c.value)}}
+}
+
+// "disallow" (draft 3) is the opposite of "type": the data must not have any of
+// the types or be valid against any of the schemas it lists.
+type disallow struct {
+	draft3Type
+}
+
+func (d disallow) Validate(keypath []string, v interface{}) []ValidationError {
+	return d.Evaluate(nil, keypath, v).Errors
+}
+
+func (d disallow) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	if len(d.draft3Type.Evaluate(scope, keypath, v).Errors) == 0 {
+		return Result{Errors: []ValidationError{{Keypath: keypath, Keyword: "disallow", Code: CodeDisallow,
+			Description: "Value must not be any of the types or schemas in 'disallow'."}}}
+	}
+	return Result{}
+}
+
+// "definitions" (and "$defs" since draft 2019-09) holds schemas that are only
+// used through references.
+type definitions struct {
+	EmbeddedSchemas
+}
+
+func (d *definitions) UnmarshalJSON(b []byte) error {
+	return d.EmbeddedSchemas.UnmarshalObject(b)
+}
+
+func (d definitions) Validate(keypath []string, v interface{}) []ValidationError {
+	return nil
 }
 
 type enum []interface{}
@@ -46,46 +124,168 @@ func (a enum) Validate(keypath []string, v interface{}) []ValidationError {
 		}
 	}
 	return []ValidationError{
-		{keypath, fmt.Sprintf("Enum error. The data must be equal to one of these values %v.", a)}}
+		{Keypath: keypath, Keyword: "enum", Code: CodeEnum, Params: map[string]interface{}{"values": []interface{}(a)},
+			Description: fmt.Sprintf("Enum error. The data must be equal to one of these values %v.", a)}}
+}
+
+// "extends" (draft 3) is a schema or an array of schemas that the data must
+// also be valid against, like allOf.
+type extends struct {
+	EmbeddedSchemas
+}
+
+func (e *extends) UnmarshalJSON(b []byte) error {
+	if err := e.EmbeddedSchemas.UnmarshalArray(b); err == nil {
+		return nil
+	}
+	return e.EmbeddedSchemas.UnmarshalSingle(b)
+}
+
+func (e extends) Validate(keypath []string, v interface{}) []ValidationError {
+	return e.Evaluate(nil, keypath, v).Errors
+}
+
+func (e extends) Evaluate(scope Scope, keypath []string, v interface{}) (r Result) {
+	for _, s := range e.EmbeddedSchemas {
+		r.merge(s.evaluate(scope, keypath, v))
+	}
+	return
+}
+
+// The "if" keyword decides which of its "then" and "else" neighbors is used to
+// validate the data. They have no effect without it.
+type ifValidator struct {
+	EmbeddedSchemas
+	thenSchema *Schema
+	elseSchema *Schema
+}
+
+func (i *ifValidator) UnmarshalJSON(b []byte) error {
+	return i.EmbeddedSchemas.UnmarshalSingle(b)
+}
+
+func (i *ifValidator) CheckNeighbors(m map[string]Node) {
+	if v, ok := m["then"]; ok {
+		if t, ok := v.Validator.(*thenValidator); ok {
+			i.thenSchema = t.EmbeddedSchemas[""]
+		}
+	}
+	if v, ok := m["else"]; ok {
+		if e, ok := v.Validator.(*elseValidator); ok {
+			i.elseSchema = e.EmbeddedSchemas[""]
+		}
+	}
+}
+
+func (i ifValidator) Validate(keypath []string, v interface{}) []ValidationError {
+	return i.Evaluate(nil, keypath, v).Errors
+}
+
+func (i ifValidator) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	s, ok := i.EmbeddedSchemas[""]
+	if !ok {
+		return Result{}
+	}
+	r := s.evaluate(scope, keypath, v)
+	if len(r.Errors) == 0 {
+		if i.thenSchema != nil {
+			r.merge(i.thenSchema.evaluate(scope, keypath, v))
+		}
+		return r
+	}
+	if i.elseSchema != nil {
+		return i.elseSchema.evaluate(scope, keypath, v)
+	}
+	return Result{}
+}
+
+type thenValidator struct {
+	EmbeddedSchemas
+}
+
+func (t *thenValidator) UnmarshalJSON(b []byte) error {
+	return t.EmbeddedSchemas.UnmarshalSingle(b)
+}
+
+func (t thenValidator) Validate(keypath []string, v interface{}) []ValidationError {
+	// Validation is handled by the "if" validator.
+	return nil
+}
+
+type elseValidator struct {
+	EmbeddedSchemas
+}
+
+func (e *elseValidator) UnmarshalJSON(b []byte) error {
+	return e.EmbeddedSchemas.UnmarshalSingle(b)
+}
+
+func (e elseValidator) Validate(keypath []string, v interface{}) []ValidationError {
+	// Validation is handled by the "if" validator.
+	return nil
 }
 
 type not struct {
 	EmbeddedSchemas
 }
 
+func (n *not) UnmarshalJSON(b []byte) error {
+	return n.EmbeddedSchemas.UnmarshalSingle(b)
+}
+
 func (n not) Validate(keypath []string, v interface{}) []ValidationError {
+	return n.Evaluate(nil, keypath, v).Errors
+}
+
+func (n not) Evaluate(scope Scope, keypath []string, v interface{}) Result {
 	s, ok := n.EmbeddedSchemas[""]
 	if !ok {
-		return nil
+		return Result{}
 	}
-	if s.Validate(keypath, v) == nil {
-		return []ValidationError{{keypath, "The 'not' schema didn't raise an error."}}
+	if len(s.evaluate(scope, keypath, v).Errors) == 0 {
+		return Result{Errors: []ValidationError{{Keypath: keypath, Keyword: "not", Code: CodeNot,
+			Description: "The 'not' schema didn't raise an error."}}}
 	}
-	return nil
+	return Result{}
 }
 
 type oneOf struct {
 	EmbeddedSchemas
 }
 
+func (a *oneOf) UnmarshalJSON(b []byte) error {
+	return a.EmbeddedSchemas.UnmarshalArray(b)
+}
+
 func (a oneOf) Validate(keypath []string, v interface{}) []ValidationError {
-	var succeeded int
-	for _, s := range a.EmbeddedSchemas {
-		if s.Validate(keypath, v) == nil {
-			succeeded++
+	return a.Evaluate(nil, keypath, v).Errors
+}
+
+func (a oneOf) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	var r Result
+	var matched []int
+	branchErrors := make([][]ValidationError, len(a.EmbeddedSchemas))
+	for i := range branchErrors {
+		sr := a.EmbeddedSchemas[strconv.Itoa(i)].evaluate(scope, keypath, v)
+		if len(sr.Errors) == 0 {
+			r = sr
+			matched = append(matched, i)
 		}
+		branchErrors[i] = sr.Errors
 	}
-	if succeeded != 1 {
-		return []ValidationError{{keypath,
-			fmt.Sprintf("Validation passed for %d schemas in 'oneOf'.", succeeded)}}
+	if len(matched) != 1 {
+		return Result{Errors: []ValidationError{{Keypath: keypath, Keyword: "oneOf", Code: CodeOneOf, BranchErrors: branchErrors,
+			Params:      map[string]interface{}{"matched": len(matched), "indexes": matched},
+			Description: fmt.Sprintf("Validation passed for %d schemas in 'oneOf'.", len(matched))}}}
 	}
-	return nil
+	return r
 }
 
 // A dummy schema used if we don't recognize a schema key. We unmarshal the key's contents anyway
 // because it might contain embedded schemas referenced elsewhere in the document.
 //
-// NOTE: this is the only validator that is hardcoded instead of being listed in validatorMap.
+// NOTE: this is the only validator that is hardcoded instead of being listed in the
+// validators of a draft.
 type other struct {
 	EmbeddedSchemas
 }
@@ -94,16 +294,135 @@ func (o other) Validate(keypath []string, v interface{}) []ValidationError {
 	return nil
 }
 
-type ref string
+// The ref validator is linked to the schema it refers to when references are
+// resolved, and follows the link when it is evaluated. Up to draft 7 the other
+// keywords of a schema containing "$ref" are ignored.
+type ref struct {
+	uri    string
+	target *Schema
+	// A JSON pointer to the reference keyword in its document.
+	pointer string
+}
+
+func (r *ref) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &r.uri)
+}
 
 func (r ref) Validate(keypath []string, v interface{}) []ValidationError {
+	return r.Evaluate(nil, keypath, v).Errors
+}
+
+func (r ref) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	if r.target == nil {
+		return Result{}
+	}
+	return r.follow(r.target, r.target.evaluate(scope, keypath, v))
+}
+
+// follow records the reference that was followed in the errors of its target,
+// unless they were found by following another reference. The schema paths of
+// the errors are moved from the target to the reference.
+func (r ref) follow(target *Schema, result Result) Result {
+	for i := range result.Errors {
+		e := &result.Errors[i]
+		if e.Ref == "" {
+			e.Ref = r.uri
+		}
+	}
+	moveSchemaPaths(result.Errors, target.pointer, r.pointer)
+	return result
+}
+
+// moveSchemaPaths replaces the prefix from of the schema paths of errs and of
+// their branch errors with to.
+func moveSchemaPaths(errs []ValidationError, from, to string) {
+	for i := range errs {
+		e := &errs[i]
+		e.SchemaPath = to + strings.TrimPrefix(e.SchemaPath, from)
+		for j := range e.trail {
+			e.trail[j].path = to + strings.TrimPrefix(e.trail[j].path, from)
+		}
+		for _, branch := range e.BranchErrors {
+			moveSchemaPaths(branch, from, to)
+		}
+	}
+}
+
+// "$recursiveRef" (draft 2019-09) refers to the root of the schema resource that
+// contains it, like a "$ref" to "#". If that resource declares a true
+// "$recursiveAnchor", the reference is followed to the outermost resource in the
+// dynamic scope that declares one too, which lets an extending schema take over
+// the recursion of the schema it extends.
+type recursiveRef struct {
+	ref
+}
+
+func (r recursiveRef) Validate(keypath []string, v interface{}) []ValidationError {
+	return r.Evaluate(nil, keypath, v).Errors
+}
+
+func (r recursiveRef) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	target := r.target
+	if target == nil {
+		return Result{}
+	}
+	if target.recursiveAnchor {
+		for _, s := range scope {
+			if s.recursiveAnchor {
+				target = s
+				break
+			}
+		}
+	}
+	return r.follow(target, target.evaluate(scope, keypath, v))
+}
+
+// "$dynamicRef" (draft 2020-12) is resolved like "$ref". If it refers to a plain
+// name fragment that its target declares with "$dynamicAnchor", the reference is
+// followed to the schema declaring the same "$dynamicAnchor" in the outermost
+// resource of the dynamic scope that has one.
+type dynamicRef struct {
+	ref
+	anchor string
+}
+
+func (r *dynamicRef) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &r.uri); err != nil {
+		return err
+	}
+	if u, err := url.Parse(r.uri); err == nil && !strings.HasPrefix(u.Fragment, "/") {
+		r.anchor = u.Fragment
+	}
 	return nil
 }
 
-type typeValidator map[string]bool
+func (r dynamicRef) Validate(keypath []string, v interface{}) []ValidationError {
+	return r.Evaluate(nil, keypath, v).Errors
+}
+
+func (r dynamicRef) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	target := r.target
+	if target == nil {
+		return Result{}
+	}
+	if r.anchor != "" && target.dynamicAnchor == r.anchor {
+		for _, s := range scope {
+			if a, ok := s.dynamicAnchors[r.anchor]; ok {
+				target = a
+				break
+			}
+		}
+	}
+	return r.follow(target, target.evaluate(scope, keypath, v))
+}
+
+type typeValidator struct {
+	types map[string]bool
+	draft Draft
+}
 
 func (t *typeValidator) UnmarshalJSON(b []byte) error {
-	*t = make(typeValidator)
+	t.types = make(map[string]bool)
 	var s string
 	var l []string
 
@@ -118,13 +437,74 @@ func (t *typeValidator) UnmarshalJSON(b []byte) error {
 	}
 
 	for _, val := range l {
-		(*t)[val] = true
+		t.types[val] = true
 	}
 	return nil
 }
 
+// In draft 3 the entries of "type" can be schemas as well as type names. The data
+// is valid if it has one of the types or is valid against one of the schemas.
+type draft3Type struct {
+	typeValidator
+	EmbeddedSchemas
+}
+
+func (t *draft3Type) UnmarshalJSON(b []byte) error {
+	t.types = make(map[string]bool)
+	t.EmbeddedSchemas = make(EmbeddedSchemas)
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		t.types[s] = true
+		return nil
+	}
+	var l []json.RawMessage
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+	for i, raw := range l {
+		if err := json.Unmarshal(raw, &s); err == nil {
+			t.types[s] = true
+			continue
+		}
+		sch := new(Schema)
+		if err := json.Unmarshal(raw, sch); err != nil {
+			return err
+		}
+		t.EmbeddedSchemas[strconv.Itoa(i)] = sch
+	}
+	return nil
+}
+
+func (t draft3Type) Validate(keypath []string, v interface{}) []ValidationError {
+	return t.Evaluate(nil, keypath, v).Errors
+}
+
+func (t draft3Type) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	if len(t.types) > 0 {
+		errs := t.typeValidator.Validate(keypath, v)
+		if errs == nil {
+			return Result{}
+		}
+		if len(t.EmbeddedSchemas) == 0 {
+			return Result{Errors: errs}
+		}
+	}
+	for _, s := range t.EmbeddedSchemas {
+		if r := s.evaluate(scope, keypath, v); len(r.Errors) == 0 {
+			return r
+		}
+	}
+	return Result{Errors: []ValidationError{{Keypath: keypath, Keyword: "type", Code: CodeType,
+		Params:      map[string]interface{}{"types": t.typeValidator.sortedTypes()},
+		Description: "Value must have one of the types or be valid against one of the schemas in 'type'."}}}
+}
+
+func (t *typeValidator) SetDraft(d Draft) {
+	t.draft = d
+}
+
 func (t typeValidator) Validate(keypath []string, v interface{}) []ValidationError {
-	if _, ok := t["any"]; ok {
+	if _, ok := t.types["any"]; ok {
 		return nil
 	}
 
@@ -144,29 +524,45 @@ func (t typeValidator) Validate(keypath []string, v interface{}) []ValidationErr
 		s = "object"
 
 	case json.Number:
-		if strings.Contains(x.String(), ".") {
-			s = "number"
-		} else {
+		s = "number"
+		if t.draft >= Draft6 {
+			// Since draft 6, a number with a zero fractional part is an integer.
+			if r, err := ratFromNumber(x); err == nil && r.IsInt() {
+				s = "integer"
+			}
+		} else if !strings.ContainsAny(x.String(), ".eE") {
 			s = "integer"
 		}
 	case float64:
-		s = "number"
+		if t.draft >= Draft6 && x == math.Trunc(x) {
+			s = "integer"
+		} else {
+			s = "number"
+		}
 	}
 
-	_, ok := t[s]
+	_, ok := t.types[s]
 
 	// The "number" type includes the "integer" type.
 	if !ok && s == "integer" {
-		_, ok = t["number"]
+		_, ok = t.types["number"]
 	}
 
 	if !ok {
-		types := make([]string, 0, len(t))
-		for key := range t {
-			types = append(types, key)
-		}
-		return []ValidationError{{keypath,
-			fmt.Sprintf("Value must be one of these types: %s. Got %s", types, s)}}
+		types := t.sortedTypes()
+		return []ValidationError{{Keypath: keypath, Keyword: "type", Code: CodeType,
+			Params:      map[string]interface{}{"types": types, "actual": s},
+			Description: fmt.Sprintf("Value must be one of these types: %s. Got %s", types, s)}}
 	}
 	return nil
 }
+
+// sortedTypes returns the types in alphabetical order.
+func (t typeValidator) sortedTypes() []string {
+	types := make([]string, 0, len(t.types))
+	for key := range t.types {
+		types = append(types, key)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 type additionalItems struct {
@@ -16,22 +17,129 @@ func (a *additionalItems) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &a.isTrue); err == nil {
 		return nil
 	}
-	return json.Unmarshal(b, &a.EmbeddedSchemas)
+	return a.EmbeddedSchemas.UnmarshalSingle(b)
 }
 
 func (a additionalItems) Validate(keypath []string, v interface{}) []ValidationError {
 	return nil
 }
 
+type contains struct {
+	EmbeddedSchemas
+	min int
+	// A negative max means there is no maximum.
+	max int
+	// Since draft 2020-12 the items that are valid against the contains schema
+	// count as evaluated.
+	evaluatesItems bool
+}
+
+func (c *contains) UnmarshalJSON(b []byte) error {
+	return c.EmbeddedSchemas.UnmarshalSingle(b)
+}
+
+func (c *contains) SetDraft(d Draft) {
+	c.evaluatesItems = d >= Draft2020
+}
+
+func (c *contains) CheckNeighbors(m map[string]Node) {
+	c.min = 1
+	c.max = -1
+	if v, ok := m["minContains"]; ok {
+		if n, ok := v.Validator.(*minContains); ok {
+			c.min = int(*n)
+		}
+	}
+	if v, ok := m["maxContains"]; ok {
+		if n, ok := v.Validator.(*maxContains); ok {
+			c.max = int(*n)
+		}
+	}
+}
+
+func (c contains) Validate(keypath []string, v interface{}) []ValidationError {
+	return c.Evaluate(nil, keypath, v).Errors
+}
+
+func (c contains) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	var r Result
+	l, ok := v.([]interface{})
+	if !ok {
+		return r
+	}
+	s, ok := c.EmbeddedSchemas[""]
+	if !ok {
+		return r
+	}
+	var matches int
+	for pos, value := range l {
+		if len(s.evaluate(scope, append(keypath, strconv.Itoa(pos)), value).Errors) == 0 {
+			matches++
+			if c.evaluatesItems {
+				r.evaluatedItem(pos)
+			}
+		}
+	}
+	if matches < c.min {
+		err := ValidationError{Keypath: keypath, Keyword: "contains", Code: CodeContainsTooFew,
+			Params:      map[string]interface{}{"limit": c.min, "actual": matches},
+			Description: fmt.Sprintf("Array must contain at least %d items that are valid against the 'contains' schema.", c.min)}
+		if c.min == 1 {
+			err.Description = "Array must contain at least one item that is valid against the 'contains' schema."
+		}
+		return Result{Errors: []ValidationError{err}}
+	}
+	if c.max >= 0 && matches > c.max {
+		return Result{Errors: []ValidationError{{Keypath: keypath, Keyword: "contains", Code: CodeContainsTooMany,
+			Params:      map[string]interface{}{"limit": c.max, "actual": matches},
+			Description: fmt.Sprintf("Array must contain at most %d items that are valid against the 'contains' schema.", c.max)}}}
+	}
+	return r
+}
+
+type maxContains int
+
+func (m *maxContains) UnmarshalJSON(b []byte) error {
+	n, err := unmarshalNonNegativeInteger(b, "maxContains")
+	*m = maxContains(n)
+	return err
+}
+
+func (m maxContains) Validate(keypath []string, v interface{}) []ValidationError {
+	// Validation is handled by the "contains" validator.
+	return nil
+}
+
+type minContains int
+
+func (m *minContains) UnmarshalJSON(b []byte) error {
+	n, err := unmarshalNonNegativeInteger(b, "minContains")
+	*m = minContains(n)
+	return err
+}
+
+func (m minContains) Validate(keypath []string, v interface{}) []ValidationError {
+	// Validation is handled by the "contains" validator.
+	return nil
+}
+
 type maxItems int
 
+func (m *maxItems) UnmarshalJSON(b []byte) error {
+	n, err := unmarshalNonNegativeInteger(b, "maxItems")
+	*m = maxItems(n)
+	return err
+}
+
 func (m maxItems) Validate(keypath []string, v interface{}) []ValidationError {
 	l, ok := v.([]interface{})
 	if !ok {
 		return nil
 	}
 	if len(l) > int(m) {
-		maxErr := ValidationError{keypath, fmt.Sprintf("Array must have fewer than %d items.", m)}
+		maxErr := ValidationError{Keypath: keypath, Keyword: "maxItems", Code: CodeMaxItems,
+			Params:      map[string]interface{}{"limit": int(m), "actual": len(l)},
+			Description: fmt.Sprintf("Array must have fewer than %d items.", m)}
 		return []ValidationError{maxErr}
 	}
 	return nil
@@ -39,13 +147,21 @@ func (m maxItems) Validate(keypath []string, v interface{}) []ValidationError {
 
 type minItems int
 
+func (m *minItems) UnmarshalJSON(b []byte) error {
+	n, err := unmarshalNonNegativeInteger(b, "minItems")
+	*m = minItems(n)
+	return err
+}
+
 func (m minItems) Validate(keypath []string, v interface{}) []ValidationError {
 	l, ok := v.([]interface{})
 	if !ok {
 		return nil
 	}
 	if len(l) < int(m) {
-		minErr := ValidationError{keypath, fmt.Sprintf("Array must have more than %d items.", m)}
+		minErr := ValidationError{Keypath: keypath, Keyword: "minItems", Code: CodeMinItems,
+			Params:      map[string]interface{}{"limit": int(m), "actual": len(l)},
+			Description: fmt.Sprintf("Array must have more than %d items.", m)}
 		return []ValidationError{minErr}
 	}
 	return nil
@@ -57,9 +173,13 @@ func (m minItems) Validate(keypath []string, v interface{}) []ValidationError {
 // [2] http://spacetelescope.github.io/understanding-json-schema/reference/array.html
 type items struct {
 	EmbeddedSchemas
-	schemaSlice       []*Schema
-	additionalAllowed bool
-	additionalItems   *Schema
+	schemaSlice        []*Schema
+	additionalAllowed  bool
+	additionalItems    *Schema
+	hasAdditionalItems bool
+	// The number of items handled by a prefixItems neighbor, which the
+	// schema form of items doesn't apply to.
+	prefixItems int
 }
 
 func (i *items) UnmarshalJSON(b []byte) error {
@@ -80,6 +200,11 @@ func (i *items) UnmarshalJSON(b []byte) error {
 
 func (i *items) CheckNeighbors(m map[string]Node) {
 	i.additionalAllowed = true
+	if v, ok := m["prefixItems"]; ok {
+		if p, ok := v.Validator.(*prefixItems); ok {
+			i.prefixItems = len(p.schemaSlice)
+		}
+	}
 	v, ok := m["additionalItems"]
 	if !ok {
 		return
@@ -90,33 +215,144 @@ func (i *items) CheckNeighbors(m map[string]Node) {
 	}
 	i.additionalAllowed = a.isTrue
 	i.additionalItems = a.EmbeddedSchemas[""]
+	i.hasAdditionalItems = true
 	return
 }
 
 func (i items) Validate(keypath []string, v interface{}) []ValidationError {
-	var valErrs []ValidationError
+	return i.Evaluate(nil, keypath, v).Errors
+}
+
+func (i items) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	var r Result
 	instances, ok := v.([]interface{})
 	if !ok {
-		return nil
+		return r
 	}
 	if s, ok := i.EmbeddedSchemas[""]; ok {
-		for pos, value := range instances {
-			valErrs = append(valErrs, s.Validate(append(keypath, strconv.Itoa(pos)), value)...)
+		for pos := i.prefixItems; pos < len(instances); pos++ {
+			r.Errors = append(r.Errors, s.evaluate(scope, append(keypath, strconv.Itoa(pos)), instances[pos]).Errors...)
+			r.evaluatedItem(pos)
 		}
-	} else if len(i.schemaSlice) > 0 {
+	} else if i.schemaSlice != nil {
 		for pos, value := range instances {
 			if pos <= len(i.schemaSlice)-1 {
 				s := i.schemaSlice[pos]
-				valErrs = append(valErrs, s.Validate(append(keypath, strconv.Itoa(pos)), value)...)
-			} else if i.additionalAllowed {
-				if i.additionalItems == nil {
-					continue
-				}
-				valErrs = append(valErrs, i.additionalItems.Validate(keypath, value)...)
+				r.Errors = append(r.Errors, s.evaluate(scope, append(keypath, strconv.Itoa(pos)), value).Errors...)
 			} else if !i.additionalAllowed {
-				return []ValidationError{{keypath, "Additional items aren't allowed."}}
+				r.Errors = append(r.Errors, ValidationError{Keypath: keypath, Keyword: "additionalItems", Code: CodeAdditionalItems,
+					Params:      map[string]interface{}{"index": pos},
+					Description: "Additional items aren't allowed."})
+				break
+			} else if i.additionalItems != nil {
+				r.Errors = append(r.Errors, i.additionalItems.evaluate(scope, append(keypath, strconv.Itoa(pos)), value).Errors...)
+			} else if !i.hasAdditionalItems {
+				continue
 			}
+			r.evaluatedItem(pos)
 		}
 	}
-	return valErrs
+	return r
+}
+
+// Since draft 2020-12, prefixItems replaces the array form of items, and the schema
+// form of items applies to the items after the ones handled by prefixItems.
+type prefixItems struct {
+	EmbeddedSchemas
+	schemaSlice []*Schema
+}
+
+func (p *prefixItems) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &p.schemaSlice); err != nil {
+		return err
+	}
+	p.EmbeddedSchemas = make(EmbeddedSchemas, len(p.schemaSlice))
+	for index, v := range p.schemaSlice {
+		p.EmbeddedSchemas[strconv.Itoa(index)] = v
+	}
+	return nil
+}
+
+func (p prefixItems) Validate(keypath []string, v interface{}) []ValidationError {
+	return p.Evaluate(nil, keypath, v).Errors
+}
+
+func (p prefixItems) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	var r Result
+	instances, ok := v.([]interface{})
+	if !ok {
+		return r
+	}
+	for pos := 0; pos < len(instances) && pos < len(p.schemaSlice); pos++ {
+		r.Errors = append(r.Errors, p.schemaSlice[pos].evaluate(scope, append(keypath, strconv.Itoa(pos)), instances[pos]).Errors...)
+		r.evaluatedItem(pos)
+	}
+	return r
+}
+
+type unevaluatedItems struct {
+	EmbeddedSchemas
+}
+
+func (u *unevaluatedItems) UnmarshalJSON(b []byte) error {
+	return u.EmbeddedSchemas.UnmarshalSingle(b)
+}
+
+func (u unevaluatedItems) Validate(keypath []string, v interface{}) []ValidationError {
+	return u.EvaluateUnevaluated(nil, keypath, v, Result{}).Errors
+}
+
+func (u unevaluatedItems) EvaluateUnevaluated(scope Scope, keypath []string, v interface{}, evaluated Result) Result {
+	var r Result
+	instances, ok := v.([]interface{})
+	if !ok {
+		return r
+	}
+	s, ok := u.EmbeddedSchemas[""]
+	if !ok {
+		return r
+	}
+	for pos, value := range instances {
+		if evaluated.EvaluatedItems[pos] {
+			continue
+		}
+		if s.boolean != nil && !*s.boolean {
+			r.Errors = append(r.Errors, ValidationError{Keypath: keypath, Keyword: "unevaluatedItems", Code: CodeUnevaluatedItems,
+				Params:      map[string]interface{}{"index": pos},
+				Description: fmt.Sprintf("Unevaluated items aren't allowed, found item %d.", pos)})
+		} else {
+			r.Errors = append(r.Errors, s.evaluate(scope, append(keypath, strconv.Itoa(pos)), value).Errors...)
+		}
+		r.evaluatedItem(pos)
+	}
+	return r
+}
+
+type uniqueItems bool
+
+func (u uniqueItems) Validate(keypath []string, v interface{}) []ValidationError {
+	if !u {
+		return nil
+	}
+	l, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	var duplicates []string
+	var pairs [][2]int
+	for i := 0; i < len(l); i++ {
+		for j := i + 1; j < len(l); j++ {
+			if DeepEqual(l[i], l[j]) {
+				duplicates = append(duplicates, fmt.Sprintf("(%d, %d)", i, j))
+				pairs = append(pairs, [2]int{i, j})
+			}
+		}
+	}
+	if len(duplicates) > 0 {
+		uniqueErr := ValidationError{Keypath: keypath, Keyword: "uniqueItems", Code: CodeUniqueItems,
+			Params:      map[string]interface{}{"duplicates": pairs},
+			Description: fmt.Sprintf("Array items must be unique. Items at these indexes are equal: %s.", strings.Join(duplicates, ", "))}
+		return []ValidationError{uniqueErr}
+	}
+	return nil
 }
@@ -2,7 +2,9 @@ package jsonschema
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 	"strings"
 )
 
@@ -48,7 +50,8 @@ func (m *maximum) SetSchema(v map[string]json.RawMessage) error {
 func (m maximum) Validate(keypath []string, v interface{}) []ValidationError {
 	normalized, err := normalizeNumber(v)
 	if err != nil {
-		return []ValidationError{{keypath, err.Error()}}
+		return []ValidationError{{Keypath: keypath, Keyword: "maximum", Code: CodeInvalidNumber,
+			Params: map[string]interface{}{"error": err.Error()}, Description: err.Error()}}
 	}
 	var isLarger bool
 	switch n := normalized.(type) {
@@ -63,8 +66,13 @@ func (m maximum) Validate(keypath []string, v interface{}) []ValidationError {
 		return nil
 	}
 	if !isLarger {
+		code := CodeMaximum
+		if m.exclusive {
+			code = CodeExclusiveMaximum
+		}
 		maxErr := fmt.Sprintf("Value must be smaller than %s.", m)
-		return []ValidationError{{keypath, maxErr}}
+		return []ValidationError{{Keypath: keypath, Keyword: "maximum", Code: code,
+			Params: map[string]interface{}{"limit": m.Number}, Description: maxErr}}
 	}
 	return nil
 }
@@ -80,7 +88,7 @@ func (m minimum) isLargerThanInt(n int64) (bool, error) {
 		if err != nil {
 			return false, nil
 		}
-		return min > n || !m.exclusive && min == n, nil
+		return min > n || m.exclusive && min == n, nil
 	} else {
 		return m.isLargerThanFloat(float64(n))
 	}
@@ -91,7 +99,7 @@ func (m minimum) isLargerThanFloat(n float64) (isLarger bool, err error) {
 	if err != nil {
 		return
 	}
-	return min > n || !m.exclusive && min == n, nil
+	return min > n || m.exclusive && min == n, nil
 }
 
 func (m *minimum) UnmarshalJSON(b []byte) error {
@@ -99,9 +107,9 @@ func (m *minimum) UnmarshalJSON(b []byte) error {
 }
 
 func (m *minimum) SetSchema(v map[string]json.RawMessage) error {
-	value, ok := v["exclusiveminimum"]
+	value, ok := v["exclusiveMinimum"]
 	if ok {
-		// Ignore errors from Unmarshal. If exclusiveminimum is a non boolean JSON
+		// Ignore errors from Unmarshal. If exclusiveMinimum is a non boolean JSON
 		// value we leave it as false.
 		json.Unmarshal(value, &m.exclusive)
 	}
@@ -111,7 +119,8 @@ func (m *minimum) SetSchema(v map[string]json.RawMessage) error {
 func (m minimum) Validate(keypath []string, v interface{}) []ValidationError {
 	normalized, err := normalizeNumber(v)
 	if err != nil {
-		return []ValidationError{{keypath, err.Error()}}
+		return []ValidationError{{Keypath: keypath, Keyword: "minimum", Code: CodeInvalidNumber,
+			Params: map[string]interface{}{"error": err.Error()}, Description: err.Error()}}
 	}
 	var isLarger bool
 	switch n := normalized.(type) {
@@ -126,38 +135,88 @@ func (m minimum) Validate(keypath []string, v interface{}) []ValidationError {
 		return nil
 	}
 	if isLarger {
+		code := CodeMinimum
+		if m.exclusive {
+			code = CodeExclusiveMinimum
+		}
 		minErr := fmt.Sprintf("Value must be larger than %s.", m)
-		return []ValidationError{{keypath, minErr}}
+		return []ValidationError{{Keypath: keypath, Keyword: "minimum", Code: code,
+			Params: map[string]interface{}{"limit": m.Number}, Description: minErr}}
 	}
 	return nil
 }
 
-type multipleOf int64
+// Since draft 6, exclusiveMaximum is a number instead of a boolean modifying maximum.
+type exclusiveMaximum struct {
+	json.Number
+}
+
+func (m *exclusiveMaximum) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &m.Number)
+}
+
+func (m exclusiveMaximum) Validate(keypath []string, v interface{}) []ValidationError {
+	return withKeyword(maximum{m.Number, true}.Validate(keypath, v), "exclusiveMaximum")
+}
+
+// Since draft 6, exclusiveMinimum is a number instead of a boolean modifying minimum.
+type exclusiveMinimum struct {
+	json.Number
+}
+
+func (m *exclusiveMinimum) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &m.Number)
+}
+
+func (m exclusiveMinimum) Validate(keypath []string, v interface{}) []ValidationError {
+	return withKeyword(minimum{m.Number, true}.Validate(keypath, v), "exclusiveMinimum")
+}
+
+// The divisor of multipleOf is kept as a fraction, so that decimal divisors
+// like 0.01 are exact, e.g. 0.0075 is a multiple of 0.0001.
+type multipleOf struct {
+	divisor *big.Rat
+	number  json.Number
+}
 
 func (m *multipleOf) UnmarshalJSON(b []byte) error {
-	var n int64
-	if err := json.Unmarshal(b, &n); err != nil {
+	if err := json.Unmarshal(b, &m.number); err != nil {
 		return err
 	}
-	*m = multipleOf(n)
+	r, ok := new(big.Rat).SetString(m.number.String())
+	if !ok || r.Sign() <= 0 {
+		return errors.New("multipleOf must be a number greater than zero")
+	}
+	m.divisor = r
 	return nil
 }
 
-// Contrary to the spec, validation doesn't support floats in the schema
-// or the data being validated. This is because of issues with math.Mod,
-// e.g. math.Mod(0.0075, 0.0001) != 0.
 func (m multipleOf) Validate(keypath []string, v interface{}) []ValidationError {
-	normalized, err := normalizeNumber(v)
+	if m.divisor == nil {
+		return nil
+	}
+	r, err := ratFromNumber(v)
 	if err != nil {
-		return []ValidationError{{keypath, err.Error()}}
+		return []ValidationError{{Keypath: keypath, Keyword: "multipleOf", Code: CodeInvalidNumber,
+			Params: map[string]interface{}{"error": err.Error()}, Description: err.Error()}}
 	}
-	n, ok := normalized.(int64)
-	if !ok {
+	if r == nil {
 		return nil
 	}
-	if n%int64(m) != 0 {
-		mulErr := ValidationError{keypath, fmt.Sprintf("Value must be a multiple of %d.", m)}
+	if !r.Quo(r, m.divisor).IsInt() {
+		mulErr := ValidationError{Keypath: keypath, Keyword: "multipleOf", Code: CodeMultipleOf,
+			Params:      map[string]interface{}{"divisor": m.number},
+			Description: fmt.Sprintf("Value must be a multiple of %s.", m.number)}
 		return []ValidationError{mulErr}
 	}
 	return nil
 }
+
+// "divisibleBy" (draft 3) is the former name of multipleOf.
+type divisibleBy struct {
+	multipleOf
+}
+
+func (d divisibleBy) Validate(keypath []string, v interface{}) []ValidationError {
+	return withKeyword(d.multipleOf.Validate(keypath, v), "divisibleBy")
+}
@@ -11,6 +11,7 @@ type additionalProperties struct {
 	EmbeddedSchemas
 	isTrue               bool
 	propertiesIsNeighbor bool
+	patternProperties    *patternProperties
 }
 
 func (a *additionalProperties) UnmarshalJSON(b []byte) error {
@@ -18,10 +19,15 @@ func (a *additionalProperties) UnmarshalJSON(b []byte) error {
 	if err := json.Unmarshal(b, &a.isTrue); err == nil {
 		return nil
 	}
-	return json.Unmarshal(b, &a.EmbeddedSchemas)
+	return a.EmbeddedSchemas.UnmarshalSingle(b)
 }
 
 func (a *additionalProperties) CheckNeighbors(m map[string]Node) {
+	if v, ok := m["patternProperties"]; ok {
+		if p, ok := v.Validator.(*patternProperties); ok {
+			a.patternProperties = p
+		}
+	}
 	v, ok := m["properties"]
 	if !ok {
 		return
@@ -34,89 +40,181 @@ func (a *additionalProperties) CheckNeighbors(m map[string]Node) {
 }
 
 func (a additionalProperties) Validate(keypath []string, v interface{}) []ValidationError {
+	return a.Evaluate(nil, keypath, v).Errors
+}
+
+func (a additionalProperties) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	var r Result
 	// In this case validation will be handled by the "properties" validator.
 	if a.propertiesIsNeighbor {
-		return nil
+		return r
 	}
-	var valErrs []ValidationError
 	dataMap, ok := v.(map[string]interface{})
 	if !ok {
-		return nil
+		return r
 	}
-	s, ok := a.EmbeddedSchemas[""]
-	if !ok {
-		return nil
-	}
-	for _, dataVal := range dataMap {
-		valErrs = append(valErrs, s.Validate(keypath, dataVal)...)
+	s := a.EmbeddedSchemas[""]
+	for dataKey, dataVal := range dataMap {
+		if a.patternProperties != nil && a.patternProperties.matches(dataKey) {
+			continue
+		}
+		if s != nil {
+			r.Errors = append(r.Errors, s.evaluate(scope, append(keypath, dataKey), dataVal).Errors...)
+		} else if !a.isTrue {
+			r.Errors = append(r.Errors, ValidationError{Keypath: keypath, Keyword: "additionalProperties", Code: CodeAdditionalProperties,
+				Params:      map[string]interface{}{"property": dataKey},
+				Description: fmt.Sprintf("Additional properties aren't allowed, found \"%v\" as one of its keys", dataKey)})
+		}
+		r.evaluatedProperty(dataKey)
 	}
-	return valErrs
+	return r
 }
 
 type dependencies struct {
 	EmbeddedSchemas
 	propertyDeps map[string]propertySet
+	// The dependencies on a single property given as a string, which is only
+	// valid in draft 3.
+	stringDeps map[string]string
+	invalid    map[string]error
 }
 
 type propertySet map[string]struct{}
 
+// The value of each dependency is either a schema or an array of property names.
 func (d *dependencies) UnmarshalJSON(b []byte) error {
-	json.Unmarshal(b, &d.EmbeddedSchemas)
-
 	var c map[string]json.RawMessage
 	if err := json.Unmarshal(b, &c); err != nil {
 		return err
 	}
+	d.EmbeddedSchemas = make(EmbeddedSchemas)
 	d.propertyDeps = make(map[string]propertySet, len(c))
+	d.stringDeps = make(map[string]string)
+	d.invalid = make(map[string]error)
 	for k, v := range c {
 		var props []string
-		if err := json.Unmarshal(v, &props); err != nil {
+		if err := json.Unmarshal(v, &props); err == nil {
+			d.propertyDeps[k] = newPropertySet(props...)
 			continue
 		}
-		set := make(propertySet, len(props))
-		for _, p := range props {
-			set[p] = struct{}{}
+		var prop string
+		if err := json.Unmarshal(v, &prop); err == nil {
+			d.stringDeps[k] = prop
+			continue
+		}
+		s := new(Schema)
+		if err := json.Unmarshal(v, s); err != nil {
+			d.invalid[k] = fmt.Errorf("invalid dependency %s: %s", k, err)
+			continue
 		}
-		d.propertyDeps[k] = set
+		d.EmbeddedSchemas[k] = s
 	}
+	return nil
+}
 
-	if len(d.propertyDeps) == 0 && len(d.EmbeddedSchemas) == 0 {
-		return errors.New("no valid schema or property dependency validators")
+func (d *dependencies) SetDraft(draft Draft) {
+	for k, prop := range d.stringDeps {
+		if draft == Draft3 {
+			d.propertyDeps[k] = newPropertySet(prop)
+		} else {
+			d.invalid[k] = fmt.Errorf("invalid dependency %s: a single property is only allowed in draft 3", k)
+		}
 	}
-	return nil
+	d.stringDeps = nil
+}
+
+func (d dependencies) invalidEntries() map[string]error {
+	return d.invalid
+}
+
+func newPropertySet(props ...string) propertySet {
+	set := make(propertySet, len(props))
+	for _, p := range props {
+		set[p] = struct{}{}
+	}
+	return set
 }
 
 func (d dependencies) Validate(keypath []string, v interface{}) []ValidationError {
-	var valErrs []ValidationError
+	return d.Evaluate(nil, keypath, v).Errors
+}
+
+func (d dependencies) Evaluate(scope Scope, keypath []string, v interface{}) Result {
 	val, ok := v.(map[string]interface{})
 	if !ok {
-		return nil
+		return Result{}
 	}
+	r := dependentSchemas{d.EmbeddedSchemas}.Evaluate(scope, keypath, v)
+	r.Errors = append(r.Errors, withKeyword(dependentRequired(d.propertyDeps).Validate(keypath, val), "dependencies")...)
+	return r
+}
 
-	// Handle schema dependencies.
-	for key, schema := range d.EmbeddedSchemas {
-		if _, ok := val[key]; !ok {
-			continue
-		}
-		valErrs = append(valErrs, schema.Validate(keypath, v)...)
+// Since draft 2019-09, the property dependencies of the dependencies keyword
+// are declared with dependentRequired.
+type dependentRequired map[string]propertySet
+
+func (d *dependentRequired) UnmarshalJSON(b []byte) error {
+	var c map[string][]string
+	if err := json.Unmarshal(b, &c); err != nil {
+		return err
 	}
+	*d = make(dependentRequired, len(c))
+	for k, props := range c {
+		(*d)[k] = newPropertySet(props...)
+	}
+	return nil
+}
 
-	// Handle property dependencies.
-	for key, set := range d.propertyDeps {
+func (d dependentRequired) Validate(keypath []string, v interface{}) []ValidationError {
+	var valErrs []ValidationError
+	val, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for key, set := range d {
 		if _, ok := val[key]; !ok {
 			continue
 		}
 		for a := range set {
 			if _, ok := val[a]; !ok {
-				valErrs = append(valErrs, ValidationError{keypath,
-					fmt.Sprintf("instance does not have a property with the name %s", a)})
+				valErrs = append(valErrs, ValidationError{Keypath: keypath, Keyword: "dependentRequired", Code: CodeDependentRequired,
+					Params:      map[string]interface{}{"property": a, "requiredBy": key},
+					Description: fmt.Sprintf("instance does not have a property with the name %s", a)})
 			}
 		}
 	}
-
 	return valErrs
 }
 
+// Since draft 2019-09, the schema dependencies of the dependencies keyword
+// are declared with dependentSchemas.
+type dependentSchemas struct {
+	EmbeddedSchemas
+}
+
+func (d *dependentSchemas) UnmarshalJSON(b []byte) error {
+	return d.EmbeddedSchemas.UnmarshalObject(b)
+}
+
+func (d dependentSchemas) Validate(keypath []string, v interface{}) []ValidationError {
+	return d.Evaluate(nil, keypath, v).Errors
+}
+
+func (d dependentSchemas) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	var r Result
+	val, ok := v.(map[string]interface{})
+	if !ok {
+		return r
+	}
+	for key, schema := range d.EmbeddedSchemas {
+		if _, ok := val[key]; !ok {
+			continue
+		}
+		r.merge(schema.evaluate(scope, keypath, v))
+	}
+	return r
+}
+
 type maxProperties int
 
 func (m *maxProperties) UnmarshalJSON(b []byte) error {
@@ -137,8 +235,9 @@ func (m maxProperties) Validate(keypath []string, v interface{}) []ValidationErr
 		return nil
 	}
 	if len(val) > int(m) {
-		return []ValidationError{{keypath,
-			fmt.Sprintf("Object has more properties than maxProperties (%d > %d)", len(val), m)}}
+		return []ValidationError{{Keypath: keypath, Keyword: "maxProperties", Code: CodeMaxProperties,
+			Params:      map[string]interface{}{"limit": int(m), "actual": len(val)},
+			Description: fmt.Sprintf("Object has more properties than maxProperties (%d > %d)", len(val), m)}}
 	}
 	return nil
 }
@@ -163,8 +262,9 @@ func (m minProperties) Validate(keypath []string, v interface{}) []ValidationErr
 		return nil
 	}
 	if len(val) < int(m) {
-		return []ValidationError{{keypath,
-			fmt.Sprintf("Object has fewer properties than minProperties (%d < %d)", len(val), m)}}
+		return []ValidationError{{Keypath: keypath, Keyword: "minProperties", Code: CodeMinProperties,
+			Params:      map[string]interface{}{"limit": int(m), "actual": len(val)},
+			Description: fmt.Sprintf("Object has fewer properties than minProperties (%d < %d)", len(val), m)}}
 	}
 	return nil
 }
@@ -210,22 +310,37 @@ func (p *patternProperties) CheckNeighbors(m map[string]Node) {
 }
 
 func (p patternProperties) Validate(keypath []string, v interface{}) []ValidationError {
+	return p.Evaluate(nil, keypath, v).Errors
+}
+
+func (p patternProperties) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	var r Result
 	if p.disabled {
-		return nil
+		return r
 	}
-	var valErrs []ValidationError
 	data, ok := v.(map[string]interface{})
 	if !ok {
-		return nil
+		return r
 	}
 	for dataKey, dataVal := range data {
 		for _, val := range p.object {
 			if val.regexp.MatchString(dataKey) {
-				valErrs = append(valErrs, val.schema.Validate(append(keypath, dataKey), dataVal)...)
+				r.Errors = append(r.Errors, val.schema.evaluate(scope, append(keypath, dataKey), dataVal).Errors...)
+				r.evaluatedProperty(dataKey)
 			}
 		}
 	}
-	return valErrs
+	return r
+}
+
+// matches reports whether the name of a property matches one of the patterns.
+func (p patternProperties) matches(name string) bool {
+	for _, val := range p.object {
+		if val.regexp.MatchString(name) {
+			return true
+		}
+	}
+	return false
 }
 
 type properties struct {
@@ -233,6 +348,11 @@ type properties struct {
 	patternProperties          *patternProperties
 	additionalPropertiesBool   bool
 	additionalPropertiesObject *Schema
+	hasAdditionalProperties    bool
+}
+
+func (p *properties) UnmarshalJSON(b []byte) error {
+	return p.EmbeddedSchemas.UnmarshalObject(b)
 }
 
 func (p *properties) CheckNeighbors(m map[string]Node) {
@@ -258,6 +378,7 @@ func (p *properties) CheckNeighbors(m map[string]Node) {
 		return
 	}
 	p.additionalPropertiesBool = a.isTrue
+	p.hasAdditionalProperties = true
 	s, ok := a.EmbeddedSchemas[""]
 	if !ok {
 		return
@@ -267,38 +388,101 @@ func (p *properties) CheckNeighbors(m map[string]Node) {
 }
 
 func (p properties) Validate(keypath []string, v interface{}) []ValidationError {
-	var valErrs []ValidationError
+	return p.Evaluate(nil, keypath, v).Errors
+}
+
+func (p properties) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	var r Result
 	dataMap, ok := v.(map[string]interface{})
 	if !ok {
-		return nil
+		return r
 	}
 	for dataKey, dataVal := range dataMap {
 		var match = false
 		schema, ok := p.EmbeddedSchemas[dataKey]
 		if ok {
-			valErrs = append(valErrs, schema.Validate(append(keypath, dataKey), dataVal)...)
+			r.Errors = append(r.Errors, schema.evaluate(scope, append(keypath, dataKey), dataVal).Errors...)
 			match = true
 		}
 		if p.patternProperties != nil {
 			for _, val := range p.patternProperties.object {
 				if val.regexp.MatchString(dataKey) {
-					valErrs = append(valErrs, val.schema.Validate(append(keypath, dataKey), dataVal)...)
+					r.Errors = append(r.Errors, val.schema.evaluate(scope, append(keypath, dataKey), dataVal).Errors...)
 					match = true
 				}
 			}
 		}
 		if match {
+			r.evaluatedProperty(dataKey)
 			continue
 		}
 		if p.additionalPropertiesObject != nil {
-			valErrs = append(valErrs, p.additionalPropertiesObject.Validate(append(keypath, dataKey), dataVal)...)
+			r.Errors = append(r.Errors, p.additionalPropertiesObject.evaluate(scope, append(keypath, dataKey), dataVal).Errors...)
+			r.evaluatedProperty(dataKey)
 			continue
 		}
 		if !p.additionalPropertiesBool {
-			valErrs = append([]ValidationError{{keypath, fmt.Sprintf("Additional properties aren't allowed, found \"%v\" as one of its keys", dataKey)}})
+			r.Errors = append(r.Errors, ValidationError{Keypath: keypath, Keyword: "additionalProperties", Code: CodeAdditionalProperties,
+				Params:      map[string]interface{}{"property": dataKey},
+				Description: fmt.Sprintf("Additional properties aren't allowed, found \"%v\" as one of its keys", dataKey)})
+		}
+		if p.hasAdditionalProperties {
+			r.evaluatedProperty(dataKey)
 		}
 	}
-	return valErrs
+	for key, schema := range p.EmbeddedSchemas {
+		if _, ok := dataMap[key]; ok {
+			continue
+		}
+		if requiredProperty(schema) {
+			r.Errors = append(r.Errors, ValidationError{Keypath: keypath, Keyword: "required", Code: CodeRequired,
+				Params:      map[string]interface{}{"property": key},
+				Description: fmt.Sprintf("Required error. The data must be an object with \"%v\" as one of its keys", key)})
+		}
+	}
+	return r
+}
+
+// requiredProperty tells whether the schema of a property has a draft 3
+// "required" of true, following its references.
+func requiredProperty(schema *Schema) bool {
+	for r, ok := schema.hasRef(); ok && r.target != nil; r, ok = schema.hasRef() {
+		schema = r.target
+	}
+	if n, ok := schema.nodes["required"]; ok {
+		if req, ok := n.Validator.(*draft3Required); ok {
+			return bool(*req)
+		}
+	}
+	return false
+}
+
+type propertyNames struct {
+	EmbeddedSchemas
+}
+
+func (p *propertyNames) UnmarshalJSON(b []byte) error {
+	return p.EmbeddedSchemas.UnmarshalSingle(b)
+}
+
+func (p propertyNames) Validate(keypath []string, v interface{}) []ValidationError {
+	return p.Evaluate(nil, keypath, v).Errors
+}
+
+func (p propertyNames) Evaluate(scope Scope, keypath []string, v interface{}) Result {
+	var r Result
+	data, ok := v.(map[string]interface{})
+	if !ok {
+		return r
+	}
+	s, ok := p.EmbeddedSchemas[""]
+	if !ok {
+		return r
+	}
+	for key := range data {
+		r.Errors = append(r.Errors, s.evaluate(scope, append(keypath, key), key).Errors...)
+	}
+	return r
 }
 
 type required map[string]struct{}
@@ -323,8 +507,57 @@ func (r required) Validate(keypath []string, v interface{}) []ValidationError {
 	}
 	for key := range r {
 		if _, ok := data[key]; !ok {
-			valErrs = append(valErrs, ValidationError{keypath, fmt.Sprintf("Required error. The data must be an object with \"%v\" as one of its keys", key)})
+			valErrs = append(valErrs, ValidationError{Keypath: keypath, Keyword: "required", Code: CodeRequired,
+				Params:      map[string]interface{}{"property": key},
+				Description: fmt.Sprintf("Required error. The data must be an object with \"%v\" as one of its keys", key)})
 		}
 	}
 	return valErrs
 }
+
+// In draft 3 "required" is a boolean in the schema of a property, which is
+// checked by the properties validator of the parent schema.
+type draft3Required bool
+
+func (r draft3Required) Validate(keypath []string, v interface{}) []ValidationError {
+	// Validation is handled by the "properties" validator.
+	return nil
+}
+
+type unevaluatedProperties struct {
+	EmbeddedSchemas
+}
+
+func (u *unevaluatedProperties) UnmarshalJSON(b []byte) error {
+	return u.EmbeddedSchemas.UnmarshalSingle(b)
+}
+
+func (u unevaluatedProperties) Validate(keypath []string, v interface{}) []ValidationError {
+	return u.EvaluateUnevaluated(nil, keypath, v, Result{}).Errors
+}
+
+func (u unevaluatedProperties) EvaluateUnevaluated(scope Scope, keypath []string, v interface{}, evaluated Result) Result {
+	var r Result
+	data, ok := v.(map[string]interface{})
+	if !ok {
+		return r
+	}
+	s, ok := u.EmbeddedSchemas[""]
+	if !ok {
+		return r
+	}
+	for key, value := range data {
+		if evaluated.EvaluatedProperties[key] {
+			continue
+		}
+		if s.boolean != nil && !*s.boolean {
+			r.Errors = append(r.Errors, ValidationError{Keypath: keypath, Keyword: "unevaluatedProperties", Code: CodeUnevaluatedProperties,
+				Params:      map[string]interface{}{"property": key},
+				Description: fmt.Sprintf("Unevaluated properties aren't allowed, found \"%v\" as one of its keys", key)})
+		} else {
+			r.Errors = append(r.Errors, s.evaluate(scope, append(keypath, key), value).Errors...)
+		}
+		r.evaluatedProperty(key)
+	}
+	return r
+}
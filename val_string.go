@@ -12,13 +12,21 @@ import (
 
 type maxLength int
 
+func (m *maxLength) UnmarshalJSON(b []byte) error {
+	n, err := unmarshalNonNegativeInteger(b, "maxLength")
+	*m = maxLength(n)
+	return err
+}
+
 func (m maxLength) Validate(keypath []string, v interface{}) []ValidationError {
 	l, ok := v.(string)
 	if !ok {
 		return nil
 	}
 	if utf8.RuneCountInString(l) > int(m) {
-		lenErr := ValidationError{keypath, fmt.Sprintf("String length must be shorter than %d characters.", m)}
+		lenErr := ValidationError{Keypath: keypath, Keyword: "maxLength", Code: CodeMaxLength,
+			Params:      map[string]interface{}{"limit": int(m), "actual": utf8.RuneCountInString(l)},
+			Description: fmt.Sprintf("String length must be shorter than %d characters.", m)}
 		return []ValidationError{lenErr}
 	}
 	return nil
@@ -26,13 +34,21 @@ func (m maxLength) Validate(keypath []string, v interface{}) []ValidationError {
 
 type minLength int
 
+func (m *minLength) UnmarshalJSON(b []byte) error {
+	n, err := unmarshalNonNegativeInteger(b, "minLength")
+	*m = minLength(n)
+	return err
+}
+
 func (m minLength) Validate(keypath []string, v interface{}) []ValidationError {
 	l, ok := v.(string)
 	if !ok {
 		return nil
 	}
 	if utf8.RuneCountInString(l) < int(m) {
-		lenErr := ValidationError{keypath, fmt.Sprintf("String length must be shorter than %d characters.", m)}
+		lenErr := ValidationError{Keypath: keypath, Keyword: "minLength", Code: CodeMinLength,
+			Params:      map[string]interface{}{"limit": int(m), "actual": utf8.RuneCountInString(l)},
+			Description: fmt.Sprintf("String length must be longer than %d characters.", m)}
 		return []ValidationError{lenErr}
 	}
 	return nil
@@ -61,7 +77,9 @@ func (p pattern) Validate(keypath []string, v interface{}) []ValidationError {
 		return nil
 	}
 	if !p.MatchString(s) {
-		patErr := ValidationError{keypath, fmt.Sprintf("String must match the pattern: \"%s\".", p.String())}
+		patErr := ValidationError{Keypath: keypath, Keyword: "pattern", Code: CodePattern,
+			Params:      map[string]interface{}{"pattern": p.String()},
+			Description: fmt.Sprintf("String must match the pattern: \"%s\".", p.String())}
 		return []ValidationError{patErr}
 	}
 	return nil
@@ -81,26 +99,26 @@ func (f format) Validate(keypath []string, v interface{}) []ValidationError {
 	switch f {
 	case "date-time":
 		if !dateTimeRegexp.MatchString(s) {
-			return []ValidationError{{keypath, "Value must conform to RFC3339."}}
+			return f.error(keypath, "Value must conform to RFC3339.")
 		}
 	case "uri":
 		if _, err := url.ParseRequestURI(s); err != nil {
-			return []ValidationError{{keypath, "Value must be a valid URI, according to RFC3986."}}
+			return f.error(keypath, "Value must be a valid URI, according to RFC3986.")
 		}
 	case "email":
 		if !mailRegexp.MatchString(s) {
-			return []ValidationError{{keypath, "Value must be a valid email address, according to RFC5322."}}
+			return f.error(keypath, "Value must be a valid email address, according to RFC5322.")
 		}
 	case "ipv4":
 		if net.ParseIP(s).To4() == nil {
-			return []ValidationError{{keypath, "Value must be a valid IPv4 address."}}
+			return f.error(keypath, "Value must be a valid IPv4 address.")
 		}
 	case "ipv6":
 		if net.ParseIP(s).To16() == nil {
-			return []ValidationError{{keypath, "Value must be a valid IPv6 address."}}
+			return f.error(keypath, "Value must be a valid IPv6 address.")
 		}
 	case "hostname":
-		formatErr := []ValidationError{{keypath, "Value must be a valid hostname."}}
+		formatErr := f.error(keypath, "Value must be a valid hostname.")
 		if !hostnameRegexp.MatchString(s) || utf8.RuneCountInString(s) > 255 {
 			return formatErr
 		}
@@ -113,3 +131,8 @@ func (f format) Validate(keypath []string, v interface{}) []ValidationError {
 	}
 	return nil
 }
+
+func (f format) error(keypath []string, description string) []ValidationError {
+	return []ValidationError{{Keypath: keypath, Keyword: "format", Code: CodeFormat,
+		Params: map[string]interface{}{"format": string(f)}, Description: description}}
+}